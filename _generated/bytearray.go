@@ -0,0 +1,19 @@
+package _generated
+
+//go:generate msgp
+
+const HashLen = 32
+
+type Hash [HashLen]byte
+
+type Letter byte
+
+type ByteArrays struct {
+	UUID    [16]uint8   `msg:"uuid"`
+	Hash    Hash        `msg:"hash"`
+	Hashes  []Hash      `msg:"hashes"`
+	Nested  [2][4]byte  `msg:"nested"`
+	Letters [3]Letter   `msg:"letters"`
+	Ptr     *[8]byte    `msg:"ptr"`
+	Ints    [2][2]int32 `msg:"ints"`
+}
@@ -0,0 +1,100 @@
+package _generated
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestByteArraysAsBin(t *testing.T) {
+	in := ByteArrays{
+		Hashes:  []Hash{{1}, {2}},
+		Letters: [3]Letter{'a', 'b', 'c'},
+		Ptr:     &[8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+		Ints:    [2][2]int32{{1, 2}, {3, 4}},
+	}
+	for i := range in.UUID {
+		in.UUID[i] = byte(i)
+	}
+	in.Hash[31] = 0xff
+	in.Nested[1][3] = 9
+
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > in.Msgsize() {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(data))
+	}
+
+	// check the wire types of the byte arrays
+	want := map[string]msgp.Type{
+		"uuid":    msgp.BinType,
+		"hash":    msgp.BinType,
+		"letters": msgp.ArrayType,
+		"ptr":     msgp.BinType,
+	}
+	sz, rest, err := msgp.ReadMapHeaderBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < sz; i++ {
+		var key string
+		key, rest, err = msgp.ReadStringBytes(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ, ok := want[key]; ok && msgp.NextType(rest) != typ {
+			t.Errorf("%s: got %s; want %s", key, msgp.NextType(rest), typ)
+		}
+		if key == "uuid" {
+			var v []byte
+			v, _, err = msgp.ReadBytesZC(rest)
+			if err != nil || len(v) != 16 {
+				t.Errorf("uuid: got %d bytes (%v)", len(v), err)
+			}
+		}
+		rest, err = msgp.Skip(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out ByteArrays
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("EncodeMsg and MarshalMsg disagree")
+	}
+	out = ByteArrays{}
+	if err := msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, in)
+	}
+}
+
+func TestByteArrayLength(t *testing.T) {
+	data := msgp.AppendBytes(nil, make([]byte, 31))
+	var h Hash
+	_, err := h.UnmarshalMsg(data)
+	if _, ok := err.(msgp.ArrayError); !ok {
+		t.Errorf("UnmarshalMsg: got error %v; want msgp.ArrayError", err)
+	}
+	err = msgp.Decode(bytes.NewReader(data), &h)
+	if _, ok := err.(msgp.ArrayError); !ok {
+		t.Errorf("DecodeMsg: got error %v; want msgp.ArrayError", err)
+	}
+}
@@ -0,0 +1,102 @@
+package _generated
+
+import (
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+//go:generate msgp
+
+//msgp:strict
+
+type Legacy struct {
+	Created time.Time   `msg:"created,codec=unixSeconds"`
+	Updated time.Time   `msg:"updated,codec=unixMillis"`
+	Seen    []time.Time `msg:"seen,codec=unixMillisList"`
+	Native  time.Time   `msg:"native"`
+}
+
+func EncodeUnixSeconds(en *msgp.Writer, t time.Time) error { return en.WriteInt64(t.Unix()) }
+
+func AppendUnixSeconds(b []byte, t time.Time) []byte { return msgp.AppendInt64(b, t.Unix()) }
+
+func DecodeUnixSeconds(dc *msgp.Reader) (time.Time, error) {
+	s, err := dc.ReadInt64()
+	return time.Unix(s, 0), err
+}
+
+func UnmarshalUnixSeconds(b []byte) (time.Time, []byte, error) {
+	s, b, err := msgp.ReadInt64Bytes(b)
+	return time.Unix(s, 0), b, err
+}
+
+func SizeUnixSeconds(t time.Time) int { return msgp.Int64Size }
+
+func EncodeUnixMillis(en *msgp.Writer, t time.Time) error {
+	return en.WriteInt64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func AppendUnixMillis(b []byte, t time.Time) []byte {
+	return msgp.AppendInt64(b, t.UnixNano()/int64(time.Millisecond))
+}
+
+func DecodeUnixMillis(dc *msgp.Reader) (time.Time, error) {
+	ms, err := dc.ReadInt64()
+	return time.Unix(0, ms*int64(time.Millisecond)), err
+}
+
+func UnmarshalUnixMillis(b []byte) (time.Time, []byte, error) {
+	ms, b, err := msgp.ReadInt64Bytes(b)
+	return time.Unix(0, ms*int64(time.Millisecond)), b, err
+}
+
+func SizeUnixMillis(t time.Time) int { return msgp.Int64Size }
+
+func EncodeUnixMillisList(en *msgp.Writer, ts []time.Time) error {
+	err := en.WriteArrayHeader(uint32(len(ts)))
+	for i := 0; err == nil && i < len(ts); i++ {
+		err = EncodeUnixMillis(en, ts[i])
+	}
+	return err
+}
+
+func AppendUnixMillisList(b []byte, ts []time.Time) []byte {
+	b = msgp.AppendArrayHeader(b, uint32(len(ts)))
+	for _, t := range ts {
+		b = AppendUnixMillis(b, t)
+	}
+	return b
+}
+
+func DecodeUnixMillisList(dc *msgp.Reader) ([]time.Time, error) {
+	sz, err := dc.ReadArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	ts := make([]time.Time, sz)
+	for i := range ts {
+		if ts[i], err = DecodeUnixMillis(dc); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
+}
+
+func UnmarshalUnixMillisList(b []byte) ([]time.Time, []byte, error) {
+	sz, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, b, err
+	}
+	ts := make([]time.Time, sz)
+	for i := range ts {
+		if ts[i], b, err = UnmarshalUnixMillis(b); err != nil {
+			return nil, b, err
+		}
+	}
+	return ts, b, nil
+}
+
+func SizeUnixMillisList(ts []time.Time) int {
+	return msgp.ArrayHeaderSize + len(ts)*msgp.Int64Size
+}
@@ -0,0 +1,72 @@
+package _generated
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestFieldCodecs(t *testing.T) {
+	now := time.Unix(1500000000, 123456789)
+	in := Legacy{
+		Created: now,
+		Updated: now,
+		Seen:    []time.Time{now, now.Add(time.Second)},
+		Native:  now,
+	}
+
+	check := func(name string, out Legacy) {
+		if !out.Created.Equal(time.Unix(now.Unix(), 0)) {
+			t.Errorf("%s: Created = %s; want whole seconds", name, out.Created)
+		}
+		ms := now.Truncate(time.Millisecond)
+		if !out.Updated.Equal(ms) {
+			t.Errorf("%s: Updated = %s; want %s", name, out.Updated, ms)
+		}
+		if len(out.Seen) != 2 || !out.Seen[1].Equal(ms.Add(time.Second)) {
+			t.Errorf("%s: Seen = %v", name, out.Seen)
+		}
+		if !out.Native.Equal(now) {
+			t.Errorf("%s: Native = %s; want %s", name, out.Native, now)
+		}
+	}
+
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > in.Msgsize() {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(data))
+	}
+
+	// the codec fields are plain integers on the wire
+	var raw map[string]interface{}
+	raw, _, err = msgp.ReadMapStrIntfBytes(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw["created"] != now.Unix() {
+		t.Errorf("created = %#v; want %d", raw["created"], now.Unix())
+	}
+
+	var out Legacy
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	check("UnmarshalMsg", out)
+
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("EncodeMsg and MarshalMsg disagree")
+	}
+	out = Legacy{}
+	if err := msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	check("DecodeMsg", out)
+}
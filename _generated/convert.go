@@ -1,6 +1,10 @@
 package _generated
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/tinylib/msgp/msgp"
+)
 
 //go:generate msgp
 
@@ -81,3 +85,81 @@ type ConvertErrVal string
 type ConvertErr struct {
 	Err ConvertErrVal
 }
+
+// Color is shimmed without a mode; the mode is
+// detected from the signatures of the shim functions.
+//msgp:shim Color as:string using:colorToString/parseColor
+//msgp:ignore Color
+
+type Color int
+
+const (
+	Red Color = iota + 1
+	Green
+	Blue
+)
+
+var errUnknownColor = errors.New("unknown color")
+
+var colorNames = map[Color]string{0: "none", Red: "red", Green: "green", Blue: "blue"}
+
+func colorToString(c Color) string { return colorNames[c] }
+
+func parseColor(s string) (Color, error) {
+	for c, name := range colorNames {
+		if name == s {
+			return c, nil
+		}
+	}
+	return 0, errUnknownColor
+}
+
+type Palette struct {
+	Main   Color            `msg:"main"`
+	Others []Color          `msg:"others"`
+	Named  map[string]Color `msg:"named"`
+}
+
+// Swatch overrides the shim for Color with a codec
+type Swatch struct {
+	Name Color `msg:"name"`
+	Code Color `msg:"code,codec=colorNum"`
+}
+
+func EncodeColorNum(en *msgp.Writer, c Color) error { return en.WriteInt(int(c)) }
+
+func AppendColorNum(b []byte, c Color) []byte { return msgp.AppendInt(b, int(c)) }
+
+func DecodeColorNum(dc *msgp.Reader) (Color, error) {
+	n, err := dc.ReadInt()
+	return Color(n), err
+}
+
+func UnmarshalColorNum(b []byte) (Color, []byte, error) {
+	n, b, err := msgp.ReadIntBytes(b)
+	return Color(n), b, err
+}
+
+func SizeColorNum(c Color) int { return msgp.IntSize }
+
+//msgp:shim Percent as:float64 using:percentToFloat/floatToPercent mode:convertto
+//msgp:ignore Percent
+
+type Percent int
+
+var errBadPercent = errors.New("percent out of range")
+
+func percentToFloat(p Percent) (float64, error) {
+	if p < 0 || p > 100 {
+		return 0, errBadPercent
+	}
+	return float64(p) / 100, nil
+}
+
+func floatToPercent(f float64) Percent { return Percent(f * 100) }
+
+type Discount struct {
+	Rate  Percent            `msg:"rate"`
+	Tiers map[string]Percent `msg:"tiers"`
+	Steps []Percent          `msg:"steps"`
+}
@@ -2,6 +2,7 @@ package _generated
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 
 	"github.com/tinylib/msgp/msgp"
@@ -57,3 +58,79 @@ func TestConvertToMarshalError(t *testing.T) {
 		t.Fatalf("expected conversion error, found %v", err.Error())
 	}
 }
+
+func TestConvertFromOnly(t *testing.T) {
+	in := Palette{Main: Red, Others: []Color{Green, Blue}, Named: map[string]Color{"sky": Blue}}
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Msgsize() < len(b) {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(b))
+	}
+	var out Palette
+	if _, err := out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if out.Main != Red || len(out.Others) != 2 || out.Others[1] != Blue || out.Named["sky"] != Blue {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, in)
+	}
+
+	// an unknown color is encoded as "", which fails to parse
+	in.Others[0] = 42
+	b, err = in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := out.UnmarshalMsg(b); err != errUnknownColor {
+		t.Errorf("UnmarshalMsg: expected conversion error, found %v", err)
+	}
+	err = msgp.Decode(bytes.NewReader(b), &out)
+	if err != errUnknownColor {
+		t.Errorf("DecodeMsg: expected conversion error, found %v", err)
+	}
+}
+
+func TestShimWithCodec(t *testing.T) {
+	in := Swatch{Name: Green, Code: Blue}
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := msgp.AppendMapHeader(nil, 2)
+	want = msgp.AppendString(want, "name")
+	want = msgp.AppendString(want, "green")
+	want = msgp.AppendString(want, "code")
+	want = msgp.AppendInt(want, int(Blue))
+	if !bytes.Equal(b, want) {
+		t.Errorf("MarshalMsg: got %x; want %x", b, want)
+	}
+	var out Swatch
+	if err := msgp.Decode(bytes.NewReader(b), &out); err != nil || out != in {
+		t.Errorf("DecodeMsg: got %+v, %v; want %+v", out, err, in)
+	}
+}
+
+func TestConvertToOnly(t *testing.T) {
+	in := Discount{Rate: 15, Tiers: map[string]Percent{"gold": 20}, Steps: []Percent{5, 10}}
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Discount
+	if _, err := out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, in)
+	}
+
+	in.Rate = 150
+	if _, err := in.MarshalMsg(nil); err != errBadPercent {
+		t.Errorf("MarshalMsg: expected conversion error, found %v", err)
+	}
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &in); err != errBadPercent {
+		t.Errorf("EncodeMsg: expected conversion error, found %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package _generated
+
+//go:generate msgp
+
+//msgp:clearomitted ClearOmitted Outer
+
+type Priority int8
+
+type Defaults struct {
+	Name     string   `msg:"name,default=anonymous"`
+	Retries  int      `msg:"retries,default=3"`
+	Ratio    float64  `msg:"ratio,default=0.5"`
+	Enabled  bool     `msg:"enabled,default=true"`
+	Mask     uint16   `msg:"mask,default=0xff"`
+	Priority Priority `msg:"priority,default=-1"`
+	Other    string   `msg:"other"`
+}
+
+type ClearOmitted struct {
+	Name   string            `msg:"name"`
+	Count  int               `msg:"count,default=7"`
+	Tags   []string          `msg:"tags"`
+	Attrs  map[string]string `msg:"attrs"`
+	Ptr    *Defaults         `msg:"ptr"`
+	Value  Defaults          `msg:"value"`
+	Data   []byte            `msg:"data"`
+	Nested struct {
+		A int    `msg:"a"`
+		B string `msg:"b"`
+	} `msg:"nested"`
+}
+
+// Outer clears a struct that has defaults, and
+// clears a struct that has defaults in turn
+type Outer struct {
+	ID    int          `msg:"id"`
+	Inner ClearOmitted `msg:"inner"`
+}
@@ -0,0 +1,137 @@
+package _generated
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestDefaultsApplied(t *testing.T) {
+	want := Defaults{
+		Name:     "anonymous",
+		Retries:  3,
+		Ratio:    0.5,
+		Enabled:  true,
+		Mask:     0xff,
+		Priority: -1,
+		Other:    "set",
+	}
+	data := msgp.AppendMapHeader(nil, 1)
+	data = msgp.AppendString(data, "other")
+	data = msgp.AppendString(data, "set")
+
+	var out Defaults
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if out != want {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, want)
+	}
+
+	out = Defaults{}
+	if err := msgp.Decode(bytes.NewReader(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != want {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, want)
+	}
+}
+
+func TestDefaultsPresent(t *testing.T) {
+	in := Defaults{Name: "x", Retries: 0, Enabled: false}
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Defaults
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("got %+v; want %+v", out, in)
+	}
+}
+
+func TestClearOmitted(t *testing.T) {
+	full := ClearOmitted{
+		Name:  "full",
+		Count: 42,
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]string{"k": "v"},
+		Ptr:   &Defaults{Name: "ptr"},
+		Value: Defaults{Name: "value"},
+		Data:  []byte("data"),
+	}
+	full.Nested.A = 1
+	full.Nested.B = "b"
+
+	// only "name" and a partial "nested" are present
+	data := msgp.AppendMapHeader(nil, 2)
+	data = msgp.AppendString(data, "name")
+	data = msgp.AppendString(data, "partial")
+	data = msgp.AppendString(data, "nested")
+	data = msgp.AppendMapHeader(data, 1)
+	data = msgp.AppendString(data, "a")
+	data = msgp.AppendInt(data, 5)
+
+	// an absent struct gets the defaults of its fields
+	want := ClearOmitted{Name: "partial", Count: 7}
+	want.Value = Defaults{Name: "anonymous", Retries: 3, Ratio: 0.5, Enabled: true, Mask: 0xff, Priority: -1}
+	want.Nested.A = 5
+
+	out := full
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, want)
+	}
+
+	out = full
+	if err := msgp.Decode(bytes.NewReader(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, want)
+	}
+}
+
+func TestClearOmittedNestedDefaults(t *testing.T) {
+	empty := msgp.AppendMapHeader(nil, 0)
+	data := msgp.AppendString(msgp.AppendMapHeader(nil, 1), "id")
+	data = msgp.AppendInt(data, 1)
+
+	// a reused value must decode like a fresh one
+	var fresh Outer
+	if _, err := fresh.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	var inner ClearOmitted
+	if _, err := inner.UnmarshalMsg(empty); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fresh.Inner, inner) {
+		t.Errorf("absent inner: got %+v; want %+v", fresh.Inner, inner)
+	}
+	if inner.Count != 7 || inner.Value.Retries != 3 {
+		t.Errorf("defaults not applied to %+v", inner)
+	}
+
+	used := Outer{ID: 2, Inner: ClearOmitted{Name: "x", Count: 1, Value: Defaults{Name: "y", Other: "z"}}}
+	out := used
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, fresh) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, fresh)
+	}
+	out = used
+	if err := msgp.Decode(bytes.NewReader(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, fresh) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, fresh)
+	}
+}
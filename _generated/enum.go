@@ -0,0 +1,47 @@
+package _generated
+
+//go:generate msgp
+
+//msgp:enum-string Weekday
+//msgp:enum-string Urgency unknown:zero
+
+type Weekday uint8
+
+const (
+	Sunday Weekday = iota
+	Monday
+	Tuesday
+)
+
+func (d Weekday) String() string {
+	switch d {
+	case Sunday:
+		return "sunday"
+	case Monday:
+		return "monday"
+	case Tuesday:
+		return "tuesday"
+	}
+	return "unknown"
+}
+
+type Urgency int
+
+const (
+	Low  Urgency = 1
+	High Urgency = 10
+)
+
+func (p Urgency) String() string {
+	if p == High {
+		return "high"
+	}
+	return "low"
+}
+
+type Schedule struct {
+	Day     Weekday            `msg:"day"`
+	Off     []Weekday          `msg:"off"`
+	Urgency Urgency            `msg:"urgency"`
+	ByName  map[string]Weekday `msg:"by_name"`
+}
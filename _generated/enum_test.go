@@ -0,0 +1,79 @@
+package _generated
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestEnumString(t *testing.T) {
+	in := Schedule{
+		Day:     Tuesday,
+		Off:     []Weekday{Sunday, Monday},
+		Urgency: High,
+		ByName:  map[string]Weekday{"first": Monday},
+	}
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > in.Msgsize() {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(data))
+	}
+
+	raw, _, err := msgp.ReadMapStrIntfBytes(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw["day"] != "tuesday" || raw["urgency"] != "high" {
+		t.Errorf("encoded as %v; want names", raw)
+	}
+
+	var out Schedule
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, in)
+	}
+	out = Schedule{}
+	if err := msgp.Decode(bytes.NewReader(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, in)
+	}
+}
+
+func TestEnumUnknown(t *testing.T) {
+	data := msgp.AppendMapHeader(nil, 2)
+	data = msgp.AppendString(data, "day")
+	data = msgp.AppendString(data, "someday")
+	data = msgp.AppendString(data, "urgency")
+	data = msgp.AppendString(data, "urgent")
+
+	var out Schedule
+	_, err := out.UnmarshalMsg(data)
+	want := msgp.EnumError{Enum: "Weekday", Value: "someday"}
+	if err != want {
+		t.Errorf("UnmarshalMsg: got error %v; want %v", err, want)
+	}
+	err = msgp.Decode(bytes.NewReader(data), &out)
+	if err != want {
+		t.Errorf("DecodeMsg: got error %v; want %v", err, want)
+	}
+
+	// Urgency decodes unknown names as zero
+	data = msgp.AppendMapHeader(nil, 1)
+	data = msgp.AppendString(data, "urgency")
+	data = msgp.AppendString(data, "urgent")
+	out = Schedule{Urgency: High}
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if out.Urgency != 0 {
+		t.Errorf("Urgency = %d; want 0", out.Urgency)
+	}
+}
@@ -0,0 +1,26 @@
+package _generated
+
+//go:generate msgp
+
+//msgp:extension Money 17
+//msgp:extension Rate 18 noregister
+
+type Money struct {
+	Currency string `msg:"currency"`
+	Units    int64  `msg:"units"`
+	Nanos    int32  `msg:"nanos"`
+}
+
+type Invoice struct {
+	ID    string  `msg:"id"`
+	Total *Money  `msg:"total,extension"`
+	Items []Money `msg:"items"`
+}
+
+// Rate is registered by the tests, in a
+// registry of their own.
+type Rate struct {
+	From  string  `msg:"from"`
+	To    string  `msg:"to"`
+	Value float64 `msg:"value"`
+}
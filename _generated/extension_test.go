@@ -0,0 +1,113 @@
+package _generated
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestExtensionMethods(t *testing.T) {
+	in := &Money{Currency: "EUR", Units: 12, Nanos: 500000000}
+	if in.ExtensionType() != 17 {
+		t.Errorf("ExtensionType() = %d; want 17", in.ExtensionType())
+	}
+	payload, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Len() != len(payload) {
+		t.Errorf("Len() = %d; want %d", in.Len(), len(payload))
+	}
+
+	var buf bytes.Buffer
+	w := msgp.NewWriter(&buf)
+	if err := w.WriteExtension(in); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	out := new(Money)
+	if err := msgp.NewReader(bytes.NewReader(buf.Bytes())).ReadExtension(out); err != nil {
+		t.Fatal(err)
+	}
+	if *out != *in {
+		t.Errorf("ReadExtension: got %+v; want %+v", out, in)
+	}
+
+	// the generated init() registers the type
+	v, err := msgp.NewReader(bytes.NewReader(buf.Bytes())).ReadIntf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := v.(*Money); !ok || *m != *in {
+		t.Errorf("ReadIntf: got %#v; want %#v", v, in)
+	}
+}
+
+func TestExtensionField(t *testing.T) {
+	in := Invoice{
+		ID:    "inv-1",
+		Total: &Money{Currency: "USD", Units: 3},
+		Items: []Money{{Currency: "USD", Units: 1}, {Currency: "USD", Units: 2}},
+	}
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Invoice
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("UnmarshalMsg: got %#v; want %#v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	out = Invoice{}
+	if err := msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("DecodeMsg: got %#v; want %#v", out, in)
+	}
+}
+
+func TestExtensionNoRegister(t *testing.T) {
+	if _, ok := msgp.DefaultExtensions.Lookup(18); ok {
+		t.Fatal("extension 18 registered in DefaultExtensions")
+	}
+	reg := msgp.NewExtensionRegistry(msgp.DefaultExtensions)
+	if err := reg.Register(18, func() msgp.Extension { return new(Rate) }); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &Rate{From: "EUR", To: "USD", Value: 1.08}
+	data := msgp.AppendArrayHeader(nil, 2)
+	data, err := msgp.AppendExtension(data, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = msgp.AppendExtension(data, &Money{Currency: "EUR", Units: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _, err := reg.ReadIntfBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := v.([]interface{})
+	if r, ok := s[0].(*Rate); !ok || *r != *in {
+		t.Errorf("ReadIntfBytes: got %#v; want %#v", s[0], in)
+	}
+	if _, ok := s[1].(*Money); !ok {
+		t.Errorf("ReadIntfBytes: got %T; want *Money", s[1])
+	}
+	if _, _, err := msgp.ReadIntfBytes(data); err != nil {
+		t.Fatal(err)
+	}
+}
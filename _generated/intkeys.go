@@ -0,0 +1,26 @@
+package _generated
+
+//go:generate msgp
+
+//msgp:intkeys IntKeys IntKeysV2 IntKeysDefaults
+
+type IntKeys struct {
+	Name  string            `msg:"#1"`
+	Count int               `msg:"#2"`
+	Tags  []string          `msg:"#3"`
+	Attrs map[string]string `msg:"#200"`
+}
+
+// IntKeysV2 is a newer revision of IntKeys
+// that dropped field 3 and added field 4.
+type IntKeysV2 struct {
+	Name  string            `msg:"#1"`
+	Count int               `msg:"#2"`
+	Attrs map[string]string `msg:"#200"`
+	Flags uint32            `msg:"#4"`
+}
+
+type IntKeysDefaults struct {
+	Name  string `msg:"#1"`
+	Count int    `msg:"#2,default=10"`
+}
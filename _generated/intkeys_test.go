@@ -0,0 +1,107 @@
+package _generated
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestIntKeysEncoding(t *testing.T) {
+	in := IntKeys{Name: "a", Count: 1}
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sz, data, err := msgp.ReadMapHeaderBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sz != 4 {
+		t.Fatalf("map size: got %d; want 4", sz)
+	}
+	for _, want := range []uint64{1, 2, 3, 200} {
+		var key uint64
+		key, data, err = msgp.ReadUint64Bytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != want {
+			t.Fatalf("got key %d; want %d", key, want)
+		}
+		data, err = msgp.Skip(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := in.MarshalMsg(nil)
+	if !bytes.Equal(buf.Bytes(), enc) {
+		t.Error("EncodeMsg and MarshalMsg disagree")
+	}
+	if in.Msgsize() < len(enc) {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(enc))
+	}
+}
+
+func TestIntKeysEvolution(t *testing.T) {
+	v1 := IntKeys{
+		Name:  "v1",
+		Count: 3,
+		Tags:  []string{"dropped"},
+		Attrs: map[string]string{"k": "v"},
+	}
+	data, err := v1.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unknown field 3 is skipped
+	want := IntKeysV2{Name: "v1", Count: 3, Attrs: v1.Attrs}
+	var v2 IntKeysV2
+	if _, err := v2.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v2, want) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", v2, want)
+	}
+	v2 = IntKeysV2{}
+	if err := msgp.Decode(bytes.NewReader(data), &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v2, want) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", v2, want)
+	}
+
+	// and back again; field 4 is skipped
+	v2.Flags = 7
+	data, err = v2.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out IntKeys
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "v1" || out.Count != 3 || out.Attrs["k"] != "v" {
+		t.Errorf("got %+v", out)
+	}
+}
+
+func TestIntKeysDefaults(t *testing.T) {
+	data := msgp.AppendMapHeader(nil, 1)
+	data = msgp.AppendUint64(data, 1)
+	data = msgp.AppendString(data, "x")
+	var out IntKeysDefaults
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "x" || out.Count != 10 {
+		t.Errorf("got %+v", out)
+	}
+}
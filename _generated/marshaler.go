@@ -0,0 +1,81 @@
+package _generated
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/url"
+)
+
+//go:generate msgp
+
+//msgp:binmarshal BinPoint url.URL
+//msgp:textmarshal Level
+
+type Marshalers struct {
+	Point    BinPoint            `msg:"point"`
+	PtrPoint *BinPoint           `msg:"ptr_point"`
+	Points   map[string]BinPoint `msg:"points"`
+	URL      *url.URL            `msg:"url"`
+	Level    Level               `msg:"level"`
+	Levels   []Level             `msg:"levels"`
+	Tagged   TextOnly            `msg:"tagged,textmarshal"`
+}
+
+// BinPoint is encoded through its
+// encoding.BinaryMarshaler implementation.
+type BinPoint struct {
+	X, Y int32
+}
+
+func (p BinPoint) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(p.X))
+	binary.BigEndian.PutUint32(b[4:], uint32(p.Y))
+	return b, nil
+}
+
+func (p *BinPoint) UnmarshalBinary(b []byte) error {
+	if len(b) != 8 {
+		return errors.New("BinPoint: bad length")
+	}
+	p.X = int32(binary.BigEndian.Uint32(b))
+	p.Y = int32(binary.BigEndian.Uint32(b[4:]))
+	return nil
+}
+
+// Level is encoded through its
+// encoding.TextMarshaler implementation.
+type Level int
+
+var levelNames = []string{"debug", "info", "error"}
+
+func (l Level) MarshalText() ([]byte, error) {
+	if l < 0 || int(l) >= len(levelNames) {
+		return nil, errors.New("Level: out of range")
+	}
+	return []byte(levelNames[l]), nil
+}
+
+func (l *Level) UnmarshalText(b []byte) error {
+	for i := range levelNames {
+		if levelNames[i] == string(b) {
+			*l = Level(i)
+			return nil
+		}
+	}
+	return errors.New("Level: unknown level " + string(b))
+}
+
+// TextOnly is only encoded as text
+// where a field asks for it.
+//msgp:ignore TextOnly
+type TextOnly struct {
+	Value string
+}
+
+func (t TextOnly) MarshalText() ([]byte, error) { return []byte(t.Value), nil }
+
+func (t *TextOnly) UnmarshalText(b []byte) error {
+	t.Value = string(b)
+	return nil
+}
@@ -0,0 +1,98 @@
+package _generated
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestMarshalersRoundTrip(t *testing.T) {
+	u, err := url.Parse("https://example.com/a?b=c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := Marshalers{
+		Point:    BinPoint{X: 1, Y: -2},
+		PtrPoint: &BinPoint{X: 3, Y: 4},
+		Points:   map[string]BinPoint{"a": {X: 5, Y: 6}},
+		URL:      u,
+		Level:    2,
+		Levels:   []Level{0, 1},
+		Tagged:   TextOnly{Value: "text"},
+	}
+
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > in.Msgsize() {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(data))
+	}
+	var out Marshalers
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("EncodeMsg and MarshalMsg disagree")
+	}
+	out = Marshalers{}
+	if err := msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, in)
+	}
+}
+
+func TestMarshalersWireTypes(t *testing.T) {
+	in := Marshalers{Level: 1, Tagged: TextOnly{Value: "x"}}
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]msgp.Type{
+		"point":  msgp.BinType,
+		"level":  msgp.StrType,
+		"tagged": msgp.StrType,
+	}
+	sz, data, err := msgp.ReadMapHeaderBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < sz; i++ {
+		var key string
+		key, data, err = msgp.ReadStringBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ, ok := want[key]; ok && msgp.NextType(data) != typ {
+			t.Errorf("%s: got %s; want %s", key, msgp.NextType(data), typ)
+		}
+		data, err = msgp.Skip(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMarshalersError(t *testing.T) {
+	in := Marshalers{Level: 10}
+	if _, err := in.MarshalMsg(nil); err == nil {
+		t.Error("expected an error from MarshalText")
+	}
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &in); err == nil {
+		t.Error("expected an error from MarshalText")
+	}
+}
@@ -0,0 +1,17 @@
+package _generated
+
+//go:generate msgp
+
+//msgp:tag json
+//msgp:naming snake_case
+
+type Account struct {
+	ID          string  `json:"id"`
+	DisplayName string  `json:"display_name,omitempty"`
+	Balance     float64 `json:",omitempty"`
+	Password    string  `json:"-"`
+	Region      string  `msg:"zone" json:"region"`
+	UserID      int64
+	HTTPProxy   string
+	First, Last string
+}
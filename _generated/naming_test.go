@@ -0,0 +1,52 @@
+package _generated
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestFieldNaming(t *testing.T) {
+	in := Account{
+		ID:          "a1",
+		DisplayName: "Ann",
+		Balance:     2.5,
+		Password:    "secret",
+		Region:      "eu",
+		UserID:      7,
+		HTTPProxy:   "proxy:80",
+		First:       "Ann",
+		Last:        "Lee",
+	}
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _, err := msgp.ReadMapStrIntfBytes(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"id":           "a1",
+		"display_name": "Ann",
+		"balance":      2.5,
+		"zone":         "eu",
+		"user_id":      int64(7),
+		"http_proxy":   "proxy:80",
+		"first":        "Ann",
+		"last":         "Lee",
+	}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("encoded as %v; want %v", raw, want)
+	}
+
+	var out Account
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	in.Password = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, in)
+	}
+}
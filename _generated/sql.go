@@ -0,0 +1,20 @@
+package _generated
+
+import (
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+//go:generate msgp
+
+//msgp:strict
+//msgp:sql Settings Tags
+
+type Settings struct {
+	Theme   string        `msg:"theme"`
+	Timeout time.Duration `msg:"timeout"`
+	Extra   msgp.SQLRaw   `msg:"extra"`
+}
+
+type Tags []string
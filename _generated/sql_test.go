@@ -0,0 +1,80 @@
+package _generated
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+var (
+	_ driver.Valuer = Settings{}
+	_ driver.Valuer = &Settings{}
+	_ sql.Scanner   = &Settings{}
+	_ driver.Valuer = Tags{}
+	_ sql.Scanner   = &Tags{}
+)
+
+func TestSQLMethods(t *testing.T) {
+	in := Settings{
+		Theme:   "dark",
+		Timeout: 5,
+		Extra:   msgp.SQLRaw(msgp.AppendString(nil, "x")),
+	}
+	v, err := in.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Settings
+	if err := out.Scan(v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v; want %+v", out, in)
+	}
+	if err := out.Scan(nil); err != nil || !reflect.DeepEqual(out, Settings{}) {
+		t.Errorf("Scan(nil): %+v, %v", out, err)
+	}
+	if err := out.Scan(42); err == nil {
+		t.Error("expected an error scanning an int")
+	}
+
+	tags := Tags{"a", "b"}
+	v, err = tags.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tout Tags
+	if err := tout.Scan(string(v.([]byte))); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, tout) {
+		t.Errorf("got %v; want %v", tout, tags)
+	}
+}
+
+func TestSQLScanReuse(t *testing.T) {
+	var s Settings
+
+	// a row without "extra" must not keep the previous row's value
+	first, err := Settings{Theme: "dark", Extra: msgp.SQLRaw(msgp.AppendInt(nil, 1))}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := msgp.AppendString(msgp.AppendString(msgp.AppendMapHeader(nil, 1), "theme"), "light")
+	if err := s.Scan(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Scan(second); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Settings{Theme: "light"}); !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v; want %+v", s, want)
+	}
+
+	if err := s.Scan(append(second, 0xc0)); err == nil {
+		t.Error("expected an error for trailing bytes")
+	}
+}
@@ -0,0 +1,28 @@
+package _generated
+
+import (
+	"math/big"
+	"net"
+	"net/netip"
+	"time"
+)
+
+//go:generate msgp
+
+type Timeout time.Duration
+
+type StdTypes struct {
+	Duration  time.Duration            `msg:"duration"`
+	Durations map[string]time.Duration `msg:"durations"`
+	Timeout   Timeout                  `msg:"timeout"`
+	Default   time.Duration            `msg:"default,default=1m30s"`
+	Int       *big.Int                 `msg:"int"`
+	IntVal    big.Int                  `msg:"int_val"`
+	Float     *big.Float               `msg:"float"`
+	Rat       *big.Rat                 `msg:"rat"`
+	Ints      []*big.Int               `msg:"ints"`
+	IP        net.IP                   `msg:"ip"`
+	IPs       []net.IP                 `msg:"ips"`
+	Addr      netip.Addr               `msg:"addr"`
+	AddrPtr   *netip.Addr              `msg:"addr_ptr"`
+}
@@ -0,0 +1,124 @@
+package _generated
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func stdTypesValue(t *testing.T) StdTypes {
+	huge, ok := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	if !ok {
+		t.Fatal("bad big.Int")
+	}
+	addr := netip.MustParseAddr("fe80::1%eth0")
+	in := StdTypes{
+		Duration:  3 * time.Second,
+		Durations: map[string]time.Duration{"a": time.Minute},
+		Timeout:   Timeout(time.Hour),
+		Default:   time.Millisecond,
+		Int:       huge,
+		Float:     new(big.Float).SetPrec(200).SetFloat64(1.5),
+		Rat:       big.NewRat(-7, 3),
+		Ints:      []*big.Int{big.NewInt(1), nil, big.NewInt(-1)},
+		IP:        net.ParseIP("192.168.0.1"),
+		IPs:       []net.IP{net.ParseIP("::1"), nil},
+		Addr:      netip.MustParseAddr("10.0.0.1"),
+		AddrPtr:   &addr,
+	}
+	in.IntVal.SetUint64(1 << 63)
+	return in
+}
+
+func checkStdTypes(t *testing.T, in, out *StdTypes) {
+	t.Helper()
+	if out.Duration != in.Duration || out.Durations["a"] != in.Durations["a"] ||
+		out.Timeout != in.Timeout || out.Default != in.Default {
+		t.Errorf("durations: got %+v", out)
+	}
+	if out.Int.Cmp(in.Int) != 0 || out.IntVal.Cmp(&in.IntVal) != 0 {
+		t.Errorf("big.Int: got %s and %s", out.Int, &out.IntVal)
+	}
+	if out.Float.Cmp(in.Float) != 0 || out.Rat.Cmp(in.Rat) != 0 {
+		t.Errorf("big.Float/big.Rat: got %s and %s", out.Float, out.Rat)
+	}
+	if len(out.Ints) != 3 || out.Ints[0].Int64() != 1 || out.Ints[1] != nil || out.Ints[2].Int64() != -1 {
+		t.Errorf("[]*big.Int: got %v", out.Ints)
+	}
+	if !out.IP.Equal(in.IP) || len(out.IPs) != 2 || !out.IPs[0].Equal(in.IPs[0]) || out.IPs[1] != nil {
+		t.Errorf("net.IP: got %v and %v", out.IP, out.IPs)
+	}
+	if out.Addr != in.Addr || out.AddrPtr == nil || *out.AddrPtr != *in.AddrPtr {
+		t.Errorf("netip.Addr: got %v and %v", out.Addr, out.AddrPtr)
+	}
+}
+
+func TestStdTypesRoundTrip(t *testing.T) {
+	in := stdTypesValue(t)
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > in.Msgsize() {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(data))
+	}
+	var out StdTypes
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	checkStdTypes(t, &in, &out)
+
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("EncodeMsg and MarshalMsg disagree")
+	}
+	out = StdTypes{}
+	if err := msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	checkStdTypes(t, &in, &out)
+}
+
+func TestStdTypesDefault(t *testing.T) {
+	data := msgp.AppendMapHeader(nil, 0)
+	var out StdTypes
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if out.Default != 90*time.Second {
+		t.Errorf("got default %s; want 1m30s", out.Default)
+	}
+}
+
+func TestStdTypesJSON(t *testing.T) {
+	in := stdTypesValue(t)
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := msgp.UnmarshalAsJSON(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out["int"] != in.Int.String() || out["rat"] != "-7/3" {
+		t.Errorf("got %v and %v", out["int"], out["rat"])
+	}
+	if out["duration"] != json.Number("3000000000") {
+		t.Errorf("got duration %v", out["duration"])
+	}
+}
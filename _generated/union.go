@@ -0,0 +1,37 @@
+package _generated
+
+//go:generate msgp
+
+//msgp:union Shape *Circle Square=square Polygon=poly
+//msgp:union Value string=s int64=i *Circle=c
+
+type Shape interface {
+	Area() float64
+}
+
+type Value interface{}
+
+type Circle struct {
+	Radius float64 `msg:"radius"`
+}
+
+func (c *Circle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+type Square struct {
+	Side float64 `msg:"side"`
+}
+
+func (s Square) Area() float64 { return s.Side * s.Side }
+
+type Polygon struct {
+	Points [][2]float64 `msg:"points"`
+}
+
+func (p Polygon) Area() float64 { return 0 }
+
+type Drawing struct {
+	Main   Shape            `msg:"main"`
+	Shapes []Shape          `msg:"shapes"`
+	Named  map[string]Shape `msg:"named"`
+	Values []Value          `msg:"values"`
+}
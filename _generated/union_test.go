@@ -0,0 +1,107 @@
+package _generated
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestUnionRoundTrip(t *testing.T) {
+	in := Drawing{
+		Main:   &Circle{Radius: 2},
+		Shapes: []Shape{Square{Side: 1}, nil, Polygon{Points: [][2]float64{{0, 0}, {1, 1}}}, (*Circle)(nil)},
+		Named:  map[string]Shape{"sq": Square{Side: 3}},
+		Values: []Value{"str", int64(-5), &Circle{Radius: 1}, nil},
+	}
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > in.Msgsize() {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(data))
+	}
+	var out Drawing
+	if _, err := out.UnmarshalMsg(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("UnmarshalMsg: got %#v; want %#v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("EncodeMsg and MarshalMsg disagree")
+	}
+	out = Drawing{}
+	if err := msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("DecodeMsg: got %#v; want %#v", out, in)
+	}
+}
+
+func TestUnionWireFormat(t *testing.T) {
+	in := Drawing{Main: Square{Side: 1}}
+	data, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := msgp.UnmarshalAsJSON(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"main":["square",{"side":1}],"shapes":[],"named":{},"values":[]}`
+	if buf.String() != want {
+		t.Errorf("got %s; want %s", buf.String(), want)
+	}
+}
+
+// Triangle is not part of the Shape union
+type Triangle struct{}
+
+func (Triangle) Area() float64 { return 0 }
+
+func TestUnionErrors(t *testing.T) {
+	in := Drawing{Main: Triangle{}}
+	_, err := in.MarshalMsg(nil)
+	if uerr, ok := err.(msgp.UnionError); !ok || uerr.Union != "Shape" || uerr.Type != reflect.TypeOf(Triangle{}) {
+		t.Errorf("MarshalMsg: got error %v", err)
+	}
+	if err := msgp.Encode(&bytes.Buffer{}, &in); err == nil {
+		t.Error("EncodeMsg: expected an error")
+	}
+
+	// ["hexagon", {}] followed by another field
+	data := msgp.AppendMapHeader(nil, 2)
+	data = msgp.AppendString(data, "main")
+	data = msgp.AppendArrayHeader(data, 2)
+	data = msgp.AppendString(data, "hexagon")
+	data = msgp.AppendMapHeader(data, 0)
+	data = msgp.AppendString(data, "values")
+	data = msgp.AppendArrayHeader(data, 0)
+
+	var out Drawing
+	rest, err := out.UnmarshalMsg(data)
+	if uerr, ok := err.(msgp.UnionError); !ok || uerr.Tag != "hexagon" {
+		t.Errorf("UnmarshalMsg: got error %v", err)
+	}
+	// the value was skipped
+	if key, _, err := msgp.ReadStringBytes(rest); err != nil || key != "values" {
+		t.Errorf("got %q, %v after the error", key, err)
+	}
+	rd := msgp.NewReader(bytes.NewReader(data))
+	err = out.DecodeMsg(rd)
+	if uerr, ok := err.(msgp.UnionError); !ok || uerr.Tag != "hexagon" {
+		t.Errorf("DecodeMsg: got error %v", err)
+	}
+	// the value was skipped
+	if key, err := rd.ReadString(); err != nil || key != "values" {
+		t.Errorf("got %q, %v after the error", key, err)
+	}
+}
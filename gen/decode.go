@@ -5,9 +5,9 @@ import (
 	"strconv"
 )
 
-func decode(w io.Writer) *decodeGen {
+func decode(w io.Writer, ids *identCounter) *decodeGen {
 	return &decodeGen{
-		p:        printer{w: w},
+		p:        printer{w: w, ids: ids},
 		hasfield: false,
 	}
 }
@@ -44,10 +44,10 @@ func (d *decodeGen) Execute(p Elem) error {
 
 	d.p.comment("DecodeMsg implements msgp.Decodable")
 
-	d.p.printf("\nfunc (%s %s) DecodeMsg(dc *msgp.Reader) (err error) {", p.Varname(), methodReceiver(p))
+	d.p.printf("\nfunc (%s %s) DecodeMsg(dc *msgp.Reader) (err error) {", p.Varname(), methodReceiver(p, d.p.ids))
 	next(d, p)
 	d.p.nakedReturn()
-	unsetReceiver(p)
+	unsetReceiver(p, d.p.ids)
 	return d.p.err
 }
 
@@ -74,7 +74,7 @@ func (d *decodeGen) assignAndCheck(name string, typ string) {
 func (d *decodeGen) structAsTuple(s *Struct) {
 	nfields := len(s.Fields)
 
-	sz := randIdent()
+	sz := d.p.ident()
 	d.p.declare(sz, u32)
 	d.assignAndCheck(sz, arrayHeader)
 	d.p.arrayCheck(strconv.Itoa(nfields), sz)
@@ -87,25 +87,48 @@ func (d *decodeGen) structAsTuple(s *Struct) {
 }
 
 func (d *decodeGen) structAsMap(s *Struct) {
-	d.needsField()
-	sz := randIdent()
+	if !s.IntKeys {
+		d.needsField()
+	}
+	sz := d.p.ident()
 	d.p.declare(sz, u32)
 	d.assignAndCheck(sz, mapHeader)
 
-	d.p.printf("\nfor %s > 0 {\n%s--", sz, sz)
-	d.assignAndCheck("field", mapKey)
-	d.p.print("\nswitch msgp.UnsafeString(field) {")
+	track := tracksAbsent(s)
+	var mask bitmask
+	if track {
+		mask = newBitmask(len(s.Fields), d.p.ids)
+		d.p.declare(mask.name, mask.typeName())
+	}
+
+	if s.IntKeys {
+		key := d.p.ident()
+		d.p.declare(key, "uint64")
+		d.p.printf("\nfor %s > 0 {\n%s--", sz, sz)
+		d.assignAndCheck(key, "Uint64")
+		d.p.printf("\nswitch %s {", key)
+	} else {
+		d.p.printf("\nfor %s > 0 {\n%s--", sz, sz)
+		d.assignAndCheck("field", mapKey)
+		d.p.print("\nswitch msgp.UnsafeString(field) {")
+	}
 	for i := range s.Fields {
-		d.p.printf("\ncase \"%s\":", s.Fields[i].FieldTag)
+		d.p.printf("\ncase %s:", caseLabel(s, i))
 		next(d, s.Fields[i].FieldElem)
 		if !d.p.ok() {
 			return
 		}
+		if track {
+			d.p.printf("\n%s", mask.set(i))
+		}
 	}
 	d.p.print("\ndefault:\nerr = dc.Skip()")
 	d.p.print(errcheck)
 	d.p.closeblock() // close switch
 	d.p.closeblock() // close for loop
+	if track {
+		d.p.applyAbsent(s, mask)
+	}
 }
 
 func (d *decodeGen) gBase(b *BaseElem) {
@@ -113,10 +136,16 @@ func (d *decodeGen) gBase(b *BaseElem) {
 		return
 	}
 
+	if b.Value == Codec {
+		d.p.printf("\n%s, err = %s(dc)", b.Varname(), b.CodecFunc("Decode"))
+		d.p.print(errcheck)
+		return
+	}
+
 	// open block for 'tmp'
 	var tmp string
 	if b.Convert {
-		tmp = randIdent()
+		tmp = d.p.ident()
 		d.p.printf("\n{ var %s %s", tmp, b.BaseType())
 	}
 
@@ -134,8 +163,12 @@ func (d *decodeGen) gBase(b *BaseElem) {
 		}
 	case IDENT:
 		d.p.printf("\nerr = %s.DecodeMsg(dc)", vname)
-	case Ext:
-		d.p.printf("\nerr = dc.ReadExtension(%s)", vname)
+	case Ext, BigInt, BigFloat, BigRat:
+		d.p.printf("\nerr = dc.Read%s(%s)", bname, vname)
+	case BinaryMarshaler:
+		d.p.printf("\nerr = dc.ReadBinaryUnmarshaler(%s)", vname)
+	case TextMarshaler:
+		d.p.printf("\nerr = dc.ReadTextUnmarshaler(%s)", vname)
 	default:
 		if b.Convert {
 			d.p.printf("\n%s, err = dc.Read%s()", tmp, bname)
@@ -147,7 +180,7 @@ func (d *decodeGen) gBase(b *BaseElem) {
 
 	// close block for 'tmp'
 	if b.Convert {
-		if b.ShimMode == Cast {
+		if !b.ShimMode.fromBaseErr() {
 			d.p.printf("\n%s = %s(%s)\n}", vname, b.FromBase(), tmp)
 		} else {
 			d.p.printf("\n%s, err = %s(%s)\n}", vname, b.FromBase(), tmp)
@@ -160,7 +193,7 @@ func (d *decodeGen) gMap(m *Map) {
 	if !d.p.ok() {
 		return
 	}
-	sz := randIdent()
+	sz := d.p.ident()
 
 	// resize or allocate map
 	d.p.declare(sz, u32)
@@ -182,7 +215,7 @@ func (d *decodeGen) gSlice(s *Slice) {
 	if !d.p.ok() {
 		return
 	}
-	sz := randIdent()
+	sz := d.p.ident()
 	d.p.declare(sz, u32)
 	d.assignAndCheck(sz, arrayHeader)
 	d.p.resizeSlice(sz, s)
@@ -195,12 +228,12 @@ func (d *decodeGen) gArray(a *Array) {
 	}
 
 	// special case if we have [const]byte
-	if be, ok := a.Els.(*BaseElem); ok && (be.Value == Byte || be.Value == Uint8) {
+	if isByteArray(a) {
 		d.p.printf("\nerr = dc.ReadExactBytes((%s)[:])", a.Varname())
 		d.p.print(errcheck)
 		return
 	}
-	sz := randIdent()
+	sz := d.p.ident()
 	d.p.declare(sz, u32)
 	d.assignAndCheck(sz, arrayHeader)
 	d.p.arrayCheck(coerceArraySize(a.Size), sz)
@@ -208,6 +241,42 @@ func (d *decodeGen) gArray(a *Array) {
 	d.p.rangeBlock(a.Index, a.Varname(), d, a.Els)
 }
 
+func (d *decodeGen) gUnion(u *Union) {
+	if !d.p.ok() {
+		return
+	}
+	d.p.print("\nif dc.IsNil() {")
+	d.p.print("\nerr = dc.ReadNil()")
+	d.p.print(errcheck)
+	d.p.printf("\n%s = nil\n} else {", u.Varname())
+	sz := d.p.ident()
+	d.p.declare(sz, u32)
+	d.assignAndCheck(sz, arrayHeader)
+	d.p.arrayCheck("2", sz)
+	tag := d.p.ident()
+	d.p.declare(tag, "[]byte")
+	d.p.printf("\n%s, err = dc.ReadMapKeyPtr()", tag)
+	d.p.print(errcheck)
+	d.p.printf("\nswitch msgp.UnsafeString(%s) {", tag)
+	for i := range u.Variants {
+		if !d.p.ok() {
+			return
+		}
+		v := &u.Variants[i]
+		vn := d.p.ident()
+		d.p.printf("\ncase %q:", v.Tag)
+		d.p.declare(vn, v.Type.TypeName())
+		v.Type.setVarname(vn, d.p.ids)
+		next(d, v.Type)
+		d.p.printf("\n%s = %s", u.Varname(), vn)
+	}
+	// skip the value so that the error is resumable
+	d.p.printf("\ndefault:\nerr = msgp.UnionError{Union: %q, Tag: string(%s)}", u.TypeName(), tag)
+	d.p.print("\nif serr := dc.Skip(); serr != nil { err = serr }\nreturn")
+	d.p.closeblock() // close switch
+	d.p.closeblock() // close else
+}
+
 func (d *decodeGen) gPtr(p *Ptr) {
 	if !d.p.ok() {
 		return
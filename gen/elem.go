@@ -2,8 +2,11 @@ package gen
 
 import (
 	"fmt"
-	"math/rand"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
 )
 
 const (
@@ -11,14 +14,28 @@ const (
 	idxLen   = 3
 )
 
-// generate a random identifier name
-func randIdent() string {
-	bts := make([]byte, idxLen)
-	for i := range bts {
-		bts[i] = idxChars[rand.Intn(len(idxChars))]
+// identCounter numbers the identifiers that are
+// generated for a type in the order they are
+// generated, so that the output is reproducible.
+// Each Printer has its own.
+type identCounter int
+
+// ident generates a unique identifier name
+func (c *identCounter) ident() string {
+	n := int(*c)
+	*c++
+	bts := make([]byte, idxLen, idxLen+1)
+	for i := idxLen - 1; i >= 0; i-- {
+		bts[i] = idxChars[n%len(idxChars)]
+		n /= len(idxChars)
+	}
+	// more than len(idxChars)^idxLen identifiers
+	// in one type get a numeric suffix
+	if n > 0 {
+		bts = strconv.AppendInt(bts, int64(n), 10)
 	}
 
-	// Use a `z` prefix so the randomly generated bytes can't conflict with
+	// Use a `z` prefix so the generated bytes can't conflict with
 	// Go keywords (such as `int` and `var`).
 	return "z" + string(bts)
 }
@@ -90,9 +107,19 @@ const (
 	Int32
 	Int64
 	Bool
-	Intf // interface{}
-	Time // time.Time
-	Ext  // extension
+	Intf     // interface{}
+	Time     // time.Time
+	Duration // time.Duration
+	BigInt   // big.Int
+	BigFloat // big.Float
+	BigRat   // big.Rat
+	IP       // net.IP
+	Addr     // netip.Addr
+	Ext      // extension
+
+	BinaryMarshaler // encoding.BinaryMarshaler, as bin
+	TextMarshaler   // encoding.TextMarshaler, as str
+	Codec           // user-supplied functions named by a `codec=` tag option
 
 	IDENT // IDENT means an unrecognized identifier
 )
@@ -121,6 +148,12 @@ var primitives = map[string]Primitive{
 	"bool":           Bool,
 	"interface{}":    Intf,
 	"time.Time":      Time,
+	"time.Duration":  Duration,
+	"big.Int":        BigInt,
+	"big.Float":      BigFloat,
+	"big.Rat":        BigRat,
+	"net.IP":         IP,
+	"netip.Addr":     Addr,
 	"msgp.Extension": Ext,
 }
 
@@ -129,6 +162,7 @@ var primitives = map[string]Primitive{
 // interfaces.
 var builtins = map[string]struct{}{
 	"msgp.Raw":    struct{}{},
+	"msgp.SQLRaw": struct{}{},
 	"msgp.Number": struct{}{},
 }
 
@@ -141,7 +175,12 @@ func (c *common) Alias(typ string)    { c.alias = typ }
 func (c *common) hidden()             {}
 
 func IsPrintable(e Elem) bool {
-	if be, ok := e.(*BaseElem); ok && !be.Printable() {
+	switch e := e.(type) {
+	case *BaseElem:
+		return e.Printable()
+	case *Union:
+		// methods can't be
+		// declared on interfaces
 		return false
 	}
 	return true
@@ -150,7 +189,7 @@ func IsPrintable(e Elem) bool {
 // Elem is a go type capable of being
 // serialized into MessagePack. It is
 // implemented by *Ptr, *Struct, *Array,
-// *Slice, *Map, and *BaseElem.
+// *Slice, *Map, *Union, and *BaseElem.
 type Elem interface {
 	// SetVarname sets this nodes
 	// variable name and recursively
@@ -159,6 +198,10 @@ type Elem interface {
 	// called on the parent of the tree.
 	SetVarname(s string)
 
+	// setVarname is SetVarname, numbering
+	// generated identifiers with 'ids'
+	setVarname(s string, ids *identCounter)
+
 	// Varname returns the variable
 	// name of the element.
 	Varname() string
@@ -195,6 +238,19 @@ func Ident(id string) *BaseElem {
 	return be
 }
 
+// CodecElem returns an element of type 'typ'
+// that is encoded and decoded with the user-supplied
+// functions named after 'codec': for example, the codec
+// "unixMillis" uses EncodeUnixMillis, DecodeUnixMillis,
+// AppendUnixMillis, UnmarshalUnixMillis and SizeUnixMillis.
+// A package-qualified codec ("pkg.unixMillis") uses
+// functions from that package.
+func CodecElem(typ string, codec string) *BaseElem {
+	be := &BaseElem{Value: Codec, Codec: codec}
+	be.common.Alias(typ)
+	return be
+}
+
 type Array struct {
 	common
 	Index string // index variable name
@@ -202,10 +258,12 @@ type Array struct {
 	Els   Elem   // child
 }
 
-func (a *Array) SetVarname(s string) {
+func (a *Array) SetVarname(s string) { a.setVarname(s, new(identCounter)) }
+
+func (a *Array) setVarname(s string, ids *identCounter) {
 	a.common.SetVarname(s)
 ridx:
-	a.Index = randIdent()
+	a.Index = ids.ident()
 
 	// try to avoid using the same
 	// index as a parent slice
@@ -213,7 +271,7 @@ ridx:
 		goto ridx
 	}
 
-	a.Els.SetVarname(fmt.Sprintf("%s[%s]", a.Varname(), a.Index))
+	a.Els.setVarname(fmt.Sprintf("%s[%s]", a.Varname(), a.Index), ids)
 }
 
 func (a *Array) TypeName() string {
@@ -240,18 +298,20 @@ type Map struct {
 	Value  Elem   // value element
 }
 
-func (m *Map) SetVarname(s string) {
+func (m *Map) SetVarname(s string) { m.setVarname(s, new(identCounter)) }
+
+func (m *Map) setVarname(s string, ids *identCounter) {
 	m.common.SetVarname(s)
 ridx:
-	m.Keyidx = randIdent()
-	m.Validx = randIdent()
+	m.Keyidx = ids.ident()
+	m.Validx = ids.ident()
 
 	// just in case
 	if m.Keyidx == m.Validx {
 		goto ridx
 	}
 
-	m.Value.SetVarname(m.Validx)
+	m.Value.setVarname(m.Validx, ids)
 }
 
 func (m *Map) TypeName() string {
@@ -276,15 +336,17 @@ type Slice struct {
 	Els   Elem // The type of each element
 }
 
-func (s *Slice) SetVarname(a string) {
+func (s *Slice) SetVarname(a string) { s.setVarname(a, new(identCounter)) }
+
+func (s *Slice) setVarname(a string, ids *identCounter) {
 	s.common.SetVarname(a)
-	s.Index = randIdent()
+	s.Index = ids.ident()
 	varName := s.Varname()
 	if varName[0] == '*' {
 		// Pointer-to-slice requires parenthesis for slicing.
 		varName = "(" + varName + ")"
 	}
-	s.Els.SetVarname(fmt.Sprintf("%s[%s]", varName, s.Index))
+	s.Els.setVarname(fmt.Sprintf("%s[%s]", varName, s.Index), ids)
 }
 
 func (s *Slice) TypeName() string {
@@ -310,7 +372,9 @@ type Ptr struct {
 	Value Elem
 }
 
-func (s *Ptr) SetVarname(a string) {
+func (s *Ptr) SetVarname(a string) { s.setVarname(a, new(identCounter)) }
+
+func (s *Ptr) setVarname(a string, ids *identCounter) {
 	s.common.SetVarname(a)
 
 	// struct fields are dereferenced
@@ -318,20 +382,20 @@ func (s *Ptr) SetVarname(a string) {
 	switch x := s.Value.(type) {
 	case *Struct:
 		// struct fields are automatically dereferenced
-		x.SetVarname(a)
+		x.setVarname(a, ids)
 		return
 
 	case *BaseElem:
 		// identities have pointer receivers
 		if x.Value == IDENT {
-			x.SetVarname(a)
+			x.setVarname(a, ids)
 		} else {
-			x.SetVarname("*" + a)
+			x.setVarname("*"+a, ids)
 		}
 		return
 
 	default:
-		s.Value.SetVarname("*" + a)
+		s.Value.setVarname("*"+a, ids)
 		return
 	}
 }
@@ -361,8 +425,10 @@ func (s *Ptr) Needsinit() bool {
 
 type Struct struct {
 	common
-	Fields  []StructField // field list
-	AsTuple bool          // write as an array instead of a map
+	Fields       []StructField // field list
+	AsTuple      bool          // write as an array instead of a map
+	ClearOmitted bool          // zero fields absent from the encoded map
+	IntKeys      bool          // use FieldNum instead of FieldTag as the map key
 }
 
 func (s *Struct) TypeName() string {
@@ -380,9 +446,11 @@ func (s *Struct) TypeName() string {
 	return s.common.alias
 }
 
-func (s *Struct) SetVarname(a string) {
+func (s *Struct) SetVarname(a string) { s.setVarname(a, new(identCounter)) }
+
+func (s *Struct) setVarname(a string, ids *identCounter) {
 	s.common.SetVarname(a)
-	writeStructFields(s.Fields, a)
+	writeStructFields(s.Fields, a, ids)
 }
 
 func (s *Struct) Copy() Elem {
@@ -403,20 +471,69 @@ func (s *Struct) Complexity() int {
 	return c
 }
 
+// Union is an interface type with a fixed
+// set of concrete types. Unions are encoded
+// as a two-element array of the variant's tag
+// and its value, or as nil if the interface
+// is nil.
+type Union struct {
+	common
+	Variants []UnionVariant
+}
+
+type UnionVariant struct {
+	Tag  string // the string that identifies the variant on the wire
+	Type Elem   // the concrete type
+}
+
+func (u *Union) TypeName() string { return u.common.alias }
+
+func (u *Union) setVarname(s string, _ *identCounter) { u.common.SetVarname(s) }
+
+func (u *Union) Copy() Elem {
+	g := *u
+	g.Variants = make([]UnionVariant, len(u.Variants))
+	for i := range u.Variants {
+		g.Variants[i].Tag = u.Variants[i].Tag
+		g.Variants[i].Type = u.Variants[i].Type.Copy()
+	}
+	return &g
+}
+
+func (u *Union) Complexity() int { return 1 + len(u.Variants) }
+
+// header returns the encoded array
+// header and tag of the i'th variant
+func (u *Union) header(i int) []byte {
+	return msgp.AppendString(msgp.AppendArrayHeader(nil, 2), u.Variants[i].Tag)
+}
+
 type StructField struct {
 	FieldTag  string // the string inside the `msg:""` tag
 	RawTag    string // the full struct tag
 	FieldName string // the name of the struct field
 	FieldElem Elem   // the field type
+	Default   string // value from the `default=` tag option, or empty
+	FieldNum  uint64 // the number in a `msg:"#N"` tag, used with IntKeys
 }
 
 type ShimMode int
 
 const (
-	Cast ShimMode = iota
-	Convert
+	Cast        ShimMode = iota // neither shim function returns an error
+	Convert                     // both shim functions return an error
+	ConvertFrom                 // only the shim from the base type returns an error
+	ConvertTo                   // only the shim to the base type returns an error
 )
 
+// toBaseErr returns whether the shim
+// to the base type returns an error
+func (m ShimMode) toBaseErr() bool { return m == Convert || m == ConvertTo }
+
+// fromBaseErr returns whether the shim
+// from the base type returns an error
+func (m ShimMode) fromBaseErr() bool { return m == Convert || m == ConvertFrom }
+
 // BaseElem is an element that
 // can be represented by a primitive
 // MessagePack type.
@@ -426,7 +543,9 @@ type BaseElem struct {
 	ShimToBase   string    // shim to base type, or empty
 	ShimFromBase string    // shim from base type, or empty
 	Value        Primitive // Type of element
+	Codec        string    // name of the codec, if Value == Codec
 	Convert      bool      // should we do an explicit conversion?
+	Struct       *Struct   // declaration of an IDENT struct in the same FileSet, if any
 	mustinline   bool      // must inline; not printable
 	needsref     bool      // needs reference for shim
 }
@@ -443,11 +562,13 @@ func (s *BaseElem) Alias(typ string) {
 	}
 }
 
-func (s *BaseElem) SetVarname(a string) {
+func (s *BaseElem) SetVarname(a string) { s.setVarname(a, nil) }
+
+func (s *BaseElem) setVarname(a string, _ *identCounter) {
 	// extensions whose parents
 	// are not pointers need to
 	// be explicitly referenced
-	if s.Value == Ext || s.needsref {
+	if s.Value.byRef() || s.needsref {
 		if strings.HasPrefix(a, "*") {
 			s.common.SetVarname(a[1:])
 			return
@@ -490,15 +611,31 @@ func (s *BaseElem) FromBase() string {
 func (s *BaseElem) BaseName() string {
 	// time is a special case;
 	// we strip the package prefix
-	if s.Value == Time {
+	switch s.Value {
+	case Time:
 		return "Time"
+	case Duration:
+		return "Duration"
 	}
 	return s.Value.String()
 }
 
+// CodecFunc returns the name of the codec
+// function for the operation 'op' (e.g. "Encode").
+func (s *BaseElem) CodecFunc(op string) string {
+	pkg, name := "", s.Codec
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		pkg, name = name[:i+1], name[i+1:]
+	}
+	if name != "" {
+		name = strings.ToUpper(name[:1]) + name[1:]
+	}
+	return pkg + op + name
+}
+
 func (s *BaseElem) BaseType() string {
 	switch s.Value {
-	case IDENT:
+	case IDENT, Codec:
 		return s.TypeName()
 
 	// exceptions to the naming/capitalization
@@ -509,8 +646,24 @@ func (s *BaseElem) BaseType() string {
 		return "[]byte"
 	case Time:
 		return "time.Time"
+	case Duration:
+		return "time.Duration"
+	case BigInt:
+		return "big.Int"
+	case BigFloat:
+		return "big.Float"
+	case BigRat:
+		return "big.Rat"
+	case IP:
+		return "net.IP"
+	case Addr:
+		return "netip.Addr"
 	case Ext:
 		return "msgp.Extension"
+	case BinaryMarshaler:
+		return "encoding.BinaryMarshaler"
+	case TextMarshaler:
+		return "encoding.TextMarshaler"
 
 	// everything else is base.String() with
 	// the first letter as lowercase
@@ -550,6 +703,17 @@ func (s *BaseElem) Resolved() bool {
 	return true
 }
 
+// byRef returns whether or not values of
+// this type are read and written through a pointer
+func (k Primitive) byRef() bool {
+	switch k {
+	case Ext, BigInt, BigFloat, BigRat, BinaryMarshaler, TextMarshaler:
+		return true
+	default:
+		return false
+	}
+}
+
 func (k Primitive) String() string {
 	switch k {
 	case String:
@@ -592,8 +756,26 @@ func (k Primitive) String() string {
 		return "Intf"
 	case Time:
 		return "time.Time"
+	case Duration:
+		return "time.Duration"
+	case BigInt:
+		return "BigInt"
+	case BigFloat:
+		return "BigFloat"
+	case BigRat:
+		return "BigRat"
+	case IP:
+		return "IP"
+	case Addr:
+		return "Addr"
 	case Ext:
 		return "Extension"
+	case BinaryMarshaler:
+		return "BinaryMarshaler"
+	case TextMarshaler:
+		return "TextMarshaler"
+	case Codec:
+		return "Codec"
 	case IDENT:
 		return "Ident"
 	default:
@@ -603,12 +785,20 @@ func (k Primitive) String() string {
 
 // writeStructFields is a trampoline for writeBase for
 // all of the fields in a struct
-func writeStructFields(s []StructField, name string) {
+func writeStructFields(s []StructField, name string, ids *identCounter) {
 	for i := range s {
-		s[i].FieldElem.SetVarname(fmt.Sprintf("%s.%s", name, s[i].FieldName))
+		s[i].FieldElem.setVarname(fmt.Sprintf("%s.%s", name, s[i].FieldName), ids)
 	}
 }
 
+// isByteArray returns whether or not a is
+// a [N]byte, which is encoded as 'bin'
+// rather than as an array of integers
+func isByteArray(a *Array) bool {
+	be, ok := a.Els.(*BaseElem)
+	return ok && (be.Value == Byte || be.Value == Uint8) && !be.Convert
+}
+
 // coerceArraySize ensures we can compare constant array lengths.
 //
 // msgpack array headers are 32 bit unsigned, which is reflected in the
@@ -619,3 +809,85 @@ func writeStructFields(s []StructField, name string) {
 func coerceArraySize(asz string) string {
 	return fmt.Sprintf("uint32(%s)", asz)
 }
+
+// zeroExpr returns an expression for the
+// zero value of the type of e.
+func zeroExpr(e Elem) string {
+	switch e := e.(type) {
+	case *Ptr, *Slice, *Map, *Union:
+		return "nil"
+	case *Struct, *Array:
+		return e.TypeName() + "{}"
+	case *BaseElem:
+		if e.ShimToBase != "" {
+			break
+		}
+		switch e.Value {
+		case Bytes, Intf:
+			return "nil"
+		case String:
+			return `""`
+		case Bool:
+			return "false"
+		case Float32, Float64, Complex64, Complex128,
+			Uint, Uint8, Uint16, Uint32, Uint64, Byte,
+			Int, Int8, Int16, Int32, Int64, Duration:
+			return "0"
+		case IP:
+			return "nil"
+		}
+	}
+	return "*new(" + e.TypeName() + ")"
+}
+
+// defaultExpr validates the default value of a struct
+// field and returns it as a Go expression that can be
+// assigned to the field. Only primitive fields may
+// have default values.
+func defaultExpr(f *StructField) (string, error) {
+	be, ok := f.FieldElem.(*BaseElem)
+	if !ok || be.ShimToBase != "" || be.Value == Codec {
+		return "", fmt.Errorf("field %s: default values are only supported for primitive types", f.FieldName)
+	}
+	var err error
+	switch be.Value {
+	case String:
+		return strconv.Quote(f.Default), nil
+	case Bool:
+		_, err = strconv.ParseBool(f.Default)
+	case Float32, Float64:
+		_, err = strconv.ParseFloat(f.Default, bitSize(be.Value))
+	case Uint, Uint8, Uint16, Uint32, Uint64, Byte:
+		_, err = strconv.ParseUint(f.Default, 0, bitSize(be.Value))
+	case Int, Int8, Int16, Int32, Int64:
+		_, err = strconv.ParseInt(f.Default, 0, bitSize(be.Value))
+	case Duration:
+		// e.g. default=1m30s
+		var d time.Duration
+		d, err = time.ParseDuration(f.Default)
+		if err == nil {
+			return strconv.FormatInt(int64(d), 10), nil
+		}
+	default:
+		return "", fmt.Errorf("field %s: default values are not supported for %s", f.FieldName, be.TypeName())
+	}
+	if err != nil {
+		return "", fmt.Errorf("field %s: invalid default value %q for %s: %s", f.FieldName, f.Default, be.TypeName(), err)
+	}
+	return f.Default, nil
+}
+
+// bitSize returns the size in bits
+// of a numeric primitive
+func bitSize(p Primitive) int {
+	switch p {
+	case Int8, Uint8, Byte:
+		return 8
+	case Int16, Uint16:
+		return 16
+	case Int32, Uint32, Float32:
+		return 32
+	default:
+		return 64
+	}
+}
@@ -7,9 +7,9 @@ import (
 	"github.com/tinylib/msgp/msgp"
 )
 
-func encode(w io.Writer) *encodeGen {
+func encode(w io.Writer, ids *identCounter) *encodeGen {
 	return &encodeGen{
-		p: printer{w: w},
+		p: printer{w: w, ids: ids},
 	}
 }
 
@@ -116,7 +116,7 @@ func (e *encodeGen) structmap(s *Struct) {
 		if !e.p.ok() {
 			return
 		}
-		data = msgp.AppendString(nil, s.Fields[i].FieldTag)
+		data = appendKey(nil, s, i)
 		e.p.printf("\n// write %q", s.Fields[i].FieldTag)
 		e.Fuse(data)
 		next(e, s.Fields[i].FieldElem)
@@ -147,6 +147,30 @@ func (e *encodeGen) gPtr(s *Ptr) {
 	e.p.closeblock()
 }
 
+func (e *encodeGen) gUnion(u *Union) {
+	if !e.p.ok() {
+		return
+	}
+	e.fuseHook()
+	vn := e.p.ident()
+	e.p.printf("\nswitch %s := %s.(type) {", vn, u.Varname())
+	e.p.print("\ncase nil:\nerr = en.WriteNil()")
+	e.p.print(errcheck)
+	for i := range u.Variants {
+		if !e.p.ok() {
+			return
+		}
+		v := &u.Variants[i]
+		e.p.printf("\ncase %s:", v.Type.TypeName())
+		e.p.printf("\n// array header, tag %q", v.Tag)
+		e.Fuse(u.header(i))
+		v.Type.setVarname(vn, e.p.ids)
+		next(e, v.Type)
+	}
+	e.p.printf("\ndefault:\nerr = msgp.UnionError{Union: %q, Type: reflect.TypeOf(%s)}\nreturn", u.TypeName(), vn)
+	e.p.closeblock()
+}
+
 func (e *encodeGen) gSlice(s *Slice) {
 	if !e.p.ok() {
 		return
@@ -162,7 +186,7 @@ func (e *encodeGen) gArray(a *Array) {
 	}
 	e.fuseHook()
 	// shortcut for [const]byte
-	if be, ok := a.Els.(*BaseElem); ok && (be.Value == Byte || be.Value == Uint8) {
+	if isByteArray(a) {
 		e.p.printf("\nerr = en.WriteBytes((%s)[:])", a.Varname())
 		e.p.print(errcheck)
 		return
@@ -178,11 +202,16 @@ func (e *encodeGen) gBase(b *BaseElem) {
 	}
 	e.fuseHook()
 	vname := b.Varname()
+	if b.Value == Codec {
+		e.p.printf("\nerr = %s(en, %s)", b.CodecFunc("Encode"), vname)
+		e.p.print(errcheck)
+		return
+	}
 	if b.Convert {
-		if b.ShimMode == Cast {
+		if !b.ShimMode.toBaseErr() {
 			vname = tobaseConvert(b)
 		} else {
-			vname = randIdent()
+			vname = e.p.ident()
 			e.p.printf("\nvar %s %s", vname, b.BaseType())
 			e.p.printf("\n%s, err = %s", vname, tobaseConvert(b))
 			e.p.printf(errcheck)
@@ -0,0 +1,56 @@
+package gen
+
+import (
+	"fmt"
+)
+
+// UnknownEnum is the policy for decoding
+// a string-encoded enum from a name that
+// isn't one of its constants.
+type UnknownEnum int
+
+const (
+	UnknownIsError UnknownEnum = iota // return a msgp.EnumError
+	UnknownIsZero                     // decode the zero value
+)
+
+// EnumFromString returns the name of the function that
+// PrintEnum generates to parse an enum of type 'name'.
+func EnumFromString(name string) string {
+	return "_" + name + "_fromString"
+}
+
+// PrintEnum prints the lookup table and parse function
+// for the enum type 'name', which is encoded as
+// the String() of its value. 'consts' are the constants
+// of the type; their names are looked up when decoding.
+// The String method of the type must have a value receiver.
+func (p *Printer) PrintEnum(name string, consts []string, unknown UnknownEnum) error {
+	if len(consts) == 0 {
+		return fmt.Errorf("%s: no constants for enum", name)
+	}
+	pr := printer{w: p.w}
+	table := "_" + name + "_msgpValues"
+
+	pr.printf("\nvar %s = func() map[string]%s {", table, name)
+	pr.printf("\nm := make(map[string]%s, %d)", name, len(consts))
+	pr.printf("\nfor _, v := range []%s{", name)
+	for i, c := range consts {
+		if i > 0 {
+			pr.print(", ")
+		}
+		pr.print(c)
+	}
+	pr.print("} {\nm[v.String()] = v\n}\nreturn m\n}()\n")
+
+	pr.printf("\n// %s returns the %s whose String() is 's'", EnumFromString(name), name)
+	pr.printf("\nfunc %s(s string) (%s, error) {", EnumFromString(name), name)
+	pr.printf("\nif v, ok := %s[s]; ok {\nreturn v, nil\n}", table)
+	switch unknown {
+	case UnknownIsZero:
+		pr.print("\nreturn 0, nil\n}\n")
+	default:
+		pr.printf("\nreturn 0, msgp.EnumError{Enum: %q, Value: s}\n}\n", name)
+	}
+	return pr.err
+}
@@ -0,0 +1,51 @@
+package gen
+
+import (
+	"fmt"
+)
+
+// PrintExtension prints the methods that make the
+// type of e implement msgp.Extension and
+// msgp.ExtensionAppender, using its MarshalMsg and
+// UnmarshalMsg methods for the extension payload.
+// If 'register' is set, it also prints an init() func
+// that registers the type as extension 'typ' in
+// msgp.DefaultExtensions.
+func (p *Printer) PrintExtension(e Elem, typ int8, register bool) error {
+	if !p.mode.isset(Marshal | Unmarshal) {
+		return fmt.Errorf("%s: extensions require the marshal and unmarshal methods", e.TypeName())
+	}
+	pr := printer{w: p.w}
+	name := e.TypeName()
+
+	pr.comment("ExtensionType implements msgp.Extension")
+	pr.printf("\nfunc (z *%s) ExtensionType() int8 { return %d }\n", name, typ)
+
+	// Len has to be exact, so it marshals z. Msgsize
+	// (through msgp.ExtensionSize) and the writers
+	// (through AppendExtensionData) don't call it.
+	pr.comment("Len implements msgp.Extension")
+	pr.printf("\nfunc (z *%s) Len() int {", name)
+	pr.print("\nb, _ := z.MarshalMsg(nil)")
+	pr.print("\nreturn len(b)\n}\n")
+
+	pr.comment("MarshalBinaryTo implements msgp.Extension")
+	pr.printf("\nfunc (z *%s) MarshalBinaryTo(b []byte) error {", name)
+	pr.print("\no, err := z.MarshalMsg(b[:0])")
+	pr.print("\nif err != nil { return err }")
+	pr.print("\ncopy(b, o)")
+	pr.print("\nreturn nil\n}\n")
+
+	pr.comment("AppendExtensionData implements msgp.ExtensionAppender")
+	pr.printf("\nfunc (z *%s) AppendExtensionData(b []byte) ([]byte, error) { return z.MarshalMsg(b) }\n", name)
+
+	pr.comment("UnmarshalBinary implements msgp.Extension")
+	pr.printf("\nfunc (z *%s) UnmarshalBinary(b []byte) error {", name)
+	pr.print("\n_, err := z.UnmarshalMsg(b)")
+	pr.print("\nreturn err\n}\n")
+
+	if register {
+		pr.printf("\nfunc init() {\nmsgp.RegisterExtension(%d, func() msgp.Extension { return new(%s) })\n}\n", typ, name)
+	}
+	return pr.err
+}
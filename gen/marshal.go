@@ -7,9 +7,9 @@ import (
 	"github.com/tinylib/msgp/msgp"
 )
 
-func marshal(w io.Writer) *marshalGen {
+func marshal(w io.Writer, ids *identCounter) *marshalGen {
 	return &marshalGen{
-		p: printer{w: w},
+		p: printer{w: w, ids: ids},
 	}
 }
 
@@ -111,7 +111,7 @@ func (m *marshalGen) mapstruct(s *Struct) {
 		if !m.p.ok() {
 			return
 		}
-		data = msgp.AppendString(nil, s.Fields[i].FieldTag)
+		data = appendKey(nil, s, i)
 
 		m.p.printf("\n// string %q", s.Fields[i].FieldTag)
 		m.Fuse(data)
@@ -157,7 +157,8 @@ func (m *marshalGen) gArray(a *Array) {
 		return
 	}
 	m.fuseHook()
-	if be, ok := a.Els.(*BaseElem); ok && be.Value == Byte {
+	// shortcut for [const]byte
+	if isByteArray(a) {
 		m.rawAppend("Bytes", "(%s)[:]", a.Varname())
 		return
 	}
@@ -176,6 +177,29 @@ func (m *marshalGen) gPtr(p *Ptr) {
 	m.p.closeblock()
 }
 
+func (m *marshalGen) gUnion(u *Union) {
+	if !m.p.ok() {
+		return
+	}
+	m.fuseHook()
+	vn := m.p.ident()
+	m.p.printf("\nswitch %s := %s.(type) {", vn, u.Varname())
+	m.p.print("\ncase nil:\no = msgp.AppendNil(o)")
+	for i := range u.Variants {
+		if !m.p.ok() {
+			return
+		}
+		v := &u.Variants[i]
+		m.p.printf("\ncase %s:", v.Type.TypeName())
+		m.p.printf("\n// array header, tag %q", v.Tag)
+		m.Fuse(u.header(i))
+		v.Type.setVarname(vn, m.p.ids)
+		next(m, v.Type)
+	}
+	m.p.printf("\ndefault:\nerr = msgp.UnionError{Union: %q, Type: reflect.TypeOf(%s)}\nreturn", u.TypeName(), vn)
+	m.p.closeblock()
+}
+
 func (m *marshalGen) gBase(b *BaseElem) {
 	if !m.p.ok() {
 		return
@@ -183,11 +207,16 @@ func (m *marshalGen) gBase(b *BaseElem) {
 	m.fuseHook()
 	vname := b.Varname()
 
+	if b.Value == Codec {
+		m.p.printf("\no = %s(o, %s)", b.CodecFunc("Append"), vname)
+		return
+	}
+
 	if b.Convert {
-		if b.ShimMode == Cast {
+		if !b.ShimMode.toBaseErr() {
 			vname = tobaseConvert(b)
 		} else {
-			vname = randIdent()
+			vname = m.p.ident()
 			m.p.printf("\nvar %s %s", vname, b.BaseType())
 			m.p.printf("\n%s, err = %s", vname, tobaseConvert(b))
 			m.p.printf(errcheck)
@@ -199,7 +228,7 @@ func (m *marshalGen) gBase(b *BaseElem) {
 	case IDENT:
 		echeck = true
 		m.p.printf("\no, err = %s.MarshalMsg(o)", vname)
-	case Intf, Ext:
+	case Intf, Ext, BinaryMarshaler, TextMarshaler:
 		echeck = true
 		m.p.printf("\no, err = msgp.Append%s(o, %s)", b.BaseName(), vname)
 	default:
@@ -21,9 +21,9 @@ const (
 	expr
 )
 
-func sizes(w io.Writer) *sizeGen {
+func sizes(w io.Writer, ids *identCounter) *sizeGen {
 	return &sizeGen{
-		p:     printer{w: w},
+		p:     printer{w: w, ids: ids},
 		state: assign,
 	}
 }
@@ -110,7 +110,7 @@ func (s *sizeGen) gStruct(st *Struct) {
 		s.addConstant(strconv.Itoa(len(data)))
 		for i := range st.Fields {
 			data = data[:0]
-			data = msgp.AppendString(data, st.Fields[i].FieldTag)
+			data = appendKey(data, st, i)
 			s.addConstant(strconv.Itoa(len(data)))
 			next(s, st.Fields[i].FieldElem)
 		}
@@ -125,6 +125,27 @@ func (s *sizeGen) gPtr(p *Ptr) {
 	s.p.closeblock()
 }
 
+func (s *sizeGen) gUnion(u *Union) {
+	if !s.p.ok() {
+		return
+	}
+	s.state = add // inner must use add
+	vn := s.p.ident()
+	s.p.printf("\nswitch %s := %s.(type) {", vn, u.Varname())
+	s.p.print("\ncase nil:\ns += msgp.NilSize")
+	for i := range u.Variants {
+		v := &u.Variants[i]
+		s.p.printf("\ncase %s:", v.Type.TypeName())
+		s.state = add
+		s.addConstant(strconv.Itoa(len(u.header(i))))
+		v.Type.setVarname(vn, s.p.ids)
+		next(s, v.Type)
+	}
+	s.p.printf("\ndefault:\n_ = %s", vn)
+	s.state = add // closing block; reset to add
+	s.p.closeblock()
+}
+
 func (s *sizeGen) gSlice(sl *Slice) {
 	if !s.p.ok() {
 		return
@@ -151,8 +172,6 @@ func (s *sizeGen) gArray(a *Array) {
 		return
 	}
 
-	s.addConstant(builtinSize(arrayHeader))
-
 	// if the array's children are a fixed
 	// size, we can compile an expression
 	// that always represents the array's wire size
@@ -161,6 +180,7 @@ func (s *sizeGen) gArray(a *Array) {
 		return
 	}
 
+	s.addConstant(builtinSize(arrayHeader))
 	s.state = add
 	s.p.rangeBlock(a.Index, a.Varname(), s, a.Els)
 	s.state = add
@@ -184,9 +204,13 @@ func (s *sizeGen) gBase(b *BaseElem) {
 	if !s.p.ok() {
 		return
 	}
-	if b.Convert && b.ShimMode == Convert {
+	if b.Value == Codec {
+		s.addConstant(b.CodecFunc("Size") + "(" + b.Varname() + ")")
+		return
+	}
+	if b.Convert && b.ShimMode.toBaseErr() && !fixedSize(b.Value) {
 		s.state = add
-		vname := randIdent()
+		vname := s.p.ident()
 		s.p.printf("\nvar %s %s", vname, b.BaseType())
 
 		// ensure we don't get "unused variable" warnings from outer slice iterations
@@ -197,7 +221,7 @@ func (s *sizeGen) gBase(b *BaseElem) {
 
 	} else {
 		vname := b.Varname()
-		if b.Convert {
+		if b.Convert && !b.ShimMode.toBaseErr() {
 			vname = tobaseConvert(b)
 		}
 		s.addConstant(basesizeExpr(b.Value, vname, b.BaseName()))
@@ -213,7 +237,7 @@ func lenExpr(sl *Slice) string {
 // size on the wire?
 func fixedSize(p Primitive) bool {
 	switch p {
-	case Intf, Ext, IDENT, Bytes, String:
+	case Intf, Ext, BinaryMarshaler, TextMarshaler, BigInt, BigFloat, BigRat, Addr, Codec, IDENT, Bytes, String:
 		return false
 	default:
 		return true
@@ -234,8 +258,11 @@ func stripRef(s string) string {
 func fixedsizeExpr(e Elem) (string, bool) {
 	switch e := e.(type) {
 	case *Array:
+		if isByteArray(e) {
+			return fmt.Sprintf("(msgp.BytesPrefixSize + (%s))", e.Size), true
+		}
 		if str, ok := fixedsizeExpr(e.Els); ok {
-			return fmt.Sprintf("(%s * (%s))", e.Size, str), true
+			return fmt.Sprintf("(msgp.ArrayHeaderSize + (%s * (%s)))", e.Size, str), true
 		}
 	case *BaseElem:
 		if fixedSize(e.Value) {
@@ -258,8 +285,8 @@ func fixedsizeExpr(e Elem) (string, bool) {
 		mhdr := msgp.AppendMapHeader(nil, uint32(len(e.Fields)))
 		hdrlen += len(mhdr)
 		var strbody []byte
-		for _, f := range e.Fields {
-			strbody = msgp.AppendString(strbody[:0], f.FieldTag)
+		for i := range e.Fields {
+			strbody = appendKey(strbody[:0], e, i)
 			hdrlen += len(strbody)
 		}
 		return fmt.Sprintf("%d + %s", hdrlen, str), true
@@ -271,9 +298,11 @@ func fixedsizeExpr(e Elem) (string, bool) {
 func basesizeExpr(value Primitive, vname, basename string) string {
 	switch value {
 	case Ext:
-		return "msgp.ExtensionPrefixSize + " + stripRef(vname) + ".Len()"
+		return "msgp.ExtensionSize(" + vname + ")"
 	case Intf:
 		return "msgp.GuessSize(" + vname + ")"
+	case BinaryMarshaler, TextMarshaler, BigInt, BigFloat, BigRat, Addr:
+		return "msgp." + basename + "Size(" + vname + ")"
 	case IDENT:
 		return vname + ".Msgsize()"
 	case Bytes:
@@ -3,6 +3,9 @@ package gen
 import (
 	"fmt"
 	"io"
+	"strconv"
+
+	"github.com/tinylib/msgp/msgp"
 )
 
 const (
@@ -95,27 +98,31 @@ const (
 
 type Printer struct {
 	gens []generator
+	mode Method
+	w    io.Writer
+	ids  identCounter // shared by gens
 }
 
 func NewPrinter(m Method, out io.Writer, tests io.Writer) *Printer {
 	if m.isset(Test) && tests == nil {
 		panic("cannot print tests with 'nil' tests argument!")
 	}
+	p := &Printer{mode: m, w: out}
 	gens := make([]generator, 0, 7)
 	if m.isset(Decode) {
-		gens = append(gens, decode(out))
+		gens = append(gens, decode(out, &p.ids))
 	}
 	if m.isset(Encode) {
-		gens = append(gens, encode(out))
+		gens = append(gens, encode(out, &p.ids))
 	}
 	if m.isset(Marshal) {
-		gens = append(gens, marshal(out))
+		gens = append(gens, marshal(out, &p.ids))
 	}
 	if m.isset(Unmarshal) {
-		gens = append(gens, unmarshal(out))
+		gens = append(gens, unmarshal(out, &p.ids))
 	}
 	if m.isset(Size) {
-		gens = append(gens, sizes(out))
+		gens = append(gens, sizes(out, &p.ids))
 	}
 	if m.isset(marshaltest) {
 		gens = append(gens, mtest(tests))
@@ -126,7 +133,8 @@ func NewPrinter(m Method, out io.Writer, tests io.Writer) *Printer {
 	if len(gens) == 0 {
 		panic("NewPrinter called with invalid method flags")
 	}
-	return &Printer{gens: gens}
+	p.gens = gens
+	return p
 }
 
 // TransformPass is a pass that transforms individual
@@ -155,8 +163,11 @@ func (p *Printer) ApplyDirective(pass Method, t TransformPass) {
 	}
 }
 
-// Print prints an Elem.
+// Print prints the methods for an Elem,
+// using "z" as the receiver.
 func (p *Printer) Print(e Elem) error {
+	p.ids = 0
+	e.setVarname("z", &p.ids)
 	for _, g := range p.gens {
 		err := g.Execute(e)
 		if err != nil {
@@ -197,6 +208,7 @@ type traversal interface {
 	gPtr(*Ptr)
 	gBase(*BaseElem)
 	gStruct(*Struct)
+	gUnion(*Union)
 }
 
 // type-switch dispatch to the correct
@@ -215,6 +227,8 @@ func next(t traversal, e Elem) {
 		t.gPtr(e)
 	case *BaseElem:
 		t.gBase(e)
+	case *Union:
+		t.gUnion(e)
 	default:
 		panic("bad element type")
 	}
@@ -250,7 +264,7 @@ func imutMethodReceiver(p Elem) string {
 // if necessary, wraps a type
 // so that its method receiver
 // is of the write type.
-func methodReceiver(p Elem) string {
+func methodReceiver(p Elem, ids *identCounter) string {
 	switch p.(type) {
 
 	// structs and arrays are
@@ -261,16 +275,16 @@ func methodReceiver(p Elem) string {
 	// set variable name to
 	// *varname
 	default:
-		p.SetVarname("(*" + p.Varname() + ")")
+		p.setVarname("(*"+p.Varname()+")", ids)
 		return "*" + p.TypeName()
 	}
 }
 
-func unsetReceiver(p Elem) {
+func unsetReceiver(p Elem, ids *identCounter) {
 	switch p.(type) {
 	case *Struct, *Array:
 	default:
-		p.SetVarname("z")
+		p.setVarname("z", ids)
 	}
 }
 
@@ -278,8 +292,12 @@ func unsetReceiver(p Elem) {
 type printer struct {
 	w   io.Writer
 	err error
+	ids *identCounter // set for method generators
 }
 
+// ident generates a unique identifier name
+func (p *printer) ident() string { return p.ids.ident() }
+
 // writes "var {{name}} {{typ}};"
 func (p *printer) declare(name string, typ string) {
 	p.printf("\nvar %s %s", name, typ)
@@ -371,6 +389,148 @@ func (p *printer) initPtr(pt *Ptr) {
 
 func (p *printer) ok() bool { return p.err == nil }
 
+// appendKey appends the map key
+// of the i'th field of s to b
+func appendKey(b []byte, s *Struct, i int) []byte {
+	if s.IntKeys {
+		return msgp.AppendUint64(b, s.Fields[i].FieldNum)
+	}
+	return msgp.AppendString(b, s.Fields[i].FieldTag)
+}
+
+// caseLabel returns the switch case that
+// matches the map key of the i'th field of s
+func caseLabel(s *Struct, i int) string {
+	if s.IntKeys {
+		return strconv.FormatUint(s.Fields[i].FieldNum, 10)
+	}
+	return strconv.Quote(s.Fields[i].FieldTag)
+}
+
+// tracksAbsent returns whether or not the decoder
+// for s has to remember which fields were present.
+func tracksAbsent(s *Struct) bool {
+	if s.ClearOmitted {
+		return true
+	}
+	for i := range s.Fields {
+		if s.Fields[i].Default != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// bitmask is a variable that records
+// which struct fields have been decoded
+type bitmask struct {
+	name string
+	bits int
+}
+
+func newBitmask(bits int, ids *identCounter) bitmask {
+	return bitmask{name: ids.ident(), bits: bits}
+}
+
+func (b bitmask) typeName() string {
+	switch {
+	case b.bits <= 8:
+		return "uint8"
+	case b.bits <= 16:
+		return "uint16"
+	case b.bits <= 32:
+		return "uint32"
+	case b.bits <= 64:
+		return "uint64"
+	default:
+		return fmt.Sprintf("[%d]uint64", (b.bits+63)/64)
+	}
+}
+
+func (b bitmask) word(i int) string {
+	if b.bits <= 64 {
+		return b.name
+	}
+	return fmt.Sprintf("%s[%d]", b.name, i/64)
+}
+
+// set returns the statement that marks bit i
+func (b bitmask) set(i int) string {
+	return fmt.Sprintf("%s |= 0x%x", b.word(i), uint64(1)<<uint(i%64))
+}
+
+// unset returns the condition that bit i is clear
+func (b bitmask) unset(i int) string {
+	return fmt.Sprintf("%s&0x%x == 0", b.word(i), uint64(1)<<uint(i%64))
+}
+
+// does:
+//
+// if mask&0x1 == 0 { z.Field = {{default or zero}} }
+//
+// (and the defaults of a cleared struct's fields)
+// for every field of s that was not decoded
+func (p *printer) applyAbsent(s *Struct, mask bitmask) {
+	for i := range s.Fields {
+		if !p.ok() {
+			return
+		}
+		f := &s.Fields[i]
+		target := stripRef(f.FieldElem.Varname())
+		switch {
+		case f.Default != "":
+			var val string
+			val, p.err = defaultExpr(f)
+			p.printf("\nif %s { %s = %s }", mask.unset(i), target, val)
+		case s.ClearOmitted:
+			p.printf("\nif %s { %s = %s", mask.unset(i), target, zeroExpr(f.FieldElem))
+			if st := fieldStruct(f.FieldElem); st != nil {
+				p.applyDefaults(target, st)
+			}
+			p.print("\n}")
+		}
+	}
+}
+
+// fieldStruct returns the struct type of e,
+// if e is a struct and its fields are known
+func fieldStruct(e Elem) *Struct {
+	switch e := e.(type) {
+	case *Struct:
+		return e
+	case *BaseElem:
+		if e.Value == IDENT {
+			return e.Struct
+		}
+	}
+	return nil
+}
+
+// applyDefaults sets the fields of the zeroed struct
+// 'target' of type s to the values that decoding an
+// empty map into it would give them, i.e. their
+// defaults, including those of nested structs if
+// s clears omitted fields
+func (p *printer) applyDefaults(target string, s *Struct) {
+	for i := range s.Fields {
+		if !p.ok() {
+			return
+		}
+		f := &s.Fields[i]
+		name := target + "." + f.FieldName
+		switch {
+		case f.Default != "":
+			var val string
+			val, p.err = defaultExpr(f)
+			p.printf("\n%s = %s", name, val)
+		case s.ClearOmitted:
+			if st := fieldStruct(f.FieldElem); st != nil {
+				p.applyDefaults(name, st)
+			}
+		}
+	}
+}
+
 func tobaseConvert(b *BaseElem) string {
 	return b.ToBase() + "(" + b.Varname() + ")"
 }
@@ -0,0 +1,42 @@
+package gen
+
+import (
+	"fmt"
+)
+
+// PrintSQL prints the methods that make the type
+// of e implement driver.Valuer and sql.Scanner,
+// storing it as MessagePack in a binary column
+// using its MarshalMsg and UnmarshalMsg methods.
+func (p *Printer) PrintSQL(e Elem) error {
+	if !p.mode.isset(Marshal | Unmarshal) {
+		return fmt.Errorf("%s: sql methods require the marshal and unmarshal methods", e.TypeName())
+	}
+	pr := printer{w: p.w}
+	name := e.TypeName()
+
+	// Value has a value receiver so that
+	// both T and *T implement driver.Valuer
+	pr.comment("Value implements driver.Valuer")
+	pr.printf("\nfunc (z %s) Value() (driver.Value, error) {", name)
+	pr.print("\nreturn z.MarshalMsg(nil)\n}\n")
+
+	// Scan starts from the zero value, since UnmarshalMsg
+	// keeps the fields that are missing from the data, and
+	// rejects trailing bytes, like msgp.SQLRaw
+	pr.comment("Scan implements sql.Scanner")
+	pr.printf("\nfunc (z *%s) Scan(src interface{}) error {", name)
+	pr.print("\nvar b []byte")
+	pr.print("\nswitch src := src.(type) {")
+	pr.print("\ncase []byte:\nb = src")
+	pr.print("\ncase string:\nb = []byte(src)")
+	pr.print("\ncase nil:")
+	pr.printf("\ndefault:\nreturn fmt.Errorf(\"msgp: can't scan %%T into %s\", src)", name)
+	pr.printf("\n}\nvar zero %s\n*z = zero", name)
+	pr.print("\nif b == nil {\nreturn nil\n}")
+	pr.print("\nrest, err := z.UnmarshalMsg(b)")
+	pr.print("\nif err != nil {\nreturn err\n}")
+	pr.printf("\nif len(rest) != 0 {\nreturn fmt.Errorf(\"msgp: %%d extra bytes after the object in %s\", len(rest))\n}", name)
+	pr.print("\nreturn nil\n}\n")
+	return pr.err
+}
@@ -5,9 +5,9 @@ import (
 	"strconv"
 )
 
-func unmarshal(w io.Writer) *unmarshalGen {
+func unmarshal(w io.Writer, ids *identCounter) *unmarshalGen {
 	return &unmarshalGen{
-		p: printer{w: w},
+		p: printer{w: w, ids: ids},
 	}
 }
 
@@ -42,11 +42,11 @@ func (u *unmarshalGen) Execute(p Elem) error {
 
 	u.p.comment("UnmarshalMsg implements msgp.Unmarshaler")
 
-	u.p.printf("\nfunc (%s %s) UnmarshalMsg(bts []byte) (o []byte, err error) {", p.Varname(), methodReceiver(p))
+	u.p.printf("\nfunc (%s %s) UnmarshalMsg(bts []byte) (o []byte, err error) {", p.Varname(), methodReceiver(p, u.p.ids))
 	next(u, p)
 	u.p.print("\no = bts")
 	u.p.nakedReturn()
-	unsetReceiver(p)
+	unsetReceiver(p, u.p.ids)
 	return u.p.err
 }
 
@@ -74,7 +74,7 @@ func (u *unmarshalGen) gStruct(s *Struct) {
 func (u *unmarshalGen) tuple(s *Struct) {
 
 	// open block
-	sz := randIdent()
+	sz := u.p.ident()
 	u.p.declare(sz, u32)
 	u.assignAndCheck(sz, arrayHeader)
 	u.p.arrayCheck(strconv.Itoa(len(s.Fields)), sz)
@@ -87,25 +87,49 @@ func (u *unmarshalGen) tuple(s *Struct) {
 }
 
 func (u *unmarshalGen) mapstruct(s *Struct) {
-	u.needsField()
-	sz := randIdent()
+	if !s.IntKeys {
+		u.needsField()
+	}
+	sz := u.p.ident()
 	u.p.declare(sz, u32)
 	u.assignAndCheck(sz, mapHeader)
 
-	u.p.printf("\nfor %s > 0 {", sz)
-	u.p.printf("\n%s--; field, bts, err = msgp.ReadMapKeyZC(bts)", sz)
-	u.p.print(errcheck)
-	u.p.print("\nswitch msgp.UnsafeString(field) {")
+	track := tracksAbsent(s)
+	var mask bitmask
+	if track {
+		mask = newBitmask(len(s.Fields), u.p.ids)
+		u.p.declare(mask.name, mask.typeName())
+	}
+
+	if s.IntKeys {
+		key := u.p.ident()
+		u.p.declare(key, "uint64")
+		u.p.printf("\nfor %s > 0 {", sz)
+		u.p.printf("\n%s--; %s, bts, err = msgp.ReadUint64Bytes(bts)", sz, key)
+		u.p.print(errcheck)
+		u.p.printf("\nswitch %s {", key)
+	} else {
+		u.p.printf("\nfor %s > 0 {", sz)
+		u.p.printf("\n%s--; field, bts, err = msgp.ReadMapKeyZC(bts)", sz)
+		u.p.print(errcheck)
+		u.p.print("\nswitch msgp.UnsafeString(field) {")
+	}
 	for i := range s.Fields {
 		if !u.p.ok() {
 			return
 		}
-		u.p.printf("\ncase \"%s\":", s.Fields[i].FieldTag)
+		u.p.printf("\ncase %s:", caseLabel(s, i))
 		next(u, s.Fields[i].FieldElem)
+		if track {
+			u.p.printf("\n%s", mask.set(i))
+		}
 	}
 	u.p.print("\ndefault:\nbts, err = msgp.Skip(bts)")
 	u.p.print(errcheck)
 	u.p.print("\n}\n}") // close switch and for loop
+	if track {
+		u.p.applyAbsent(s, mask)
+	}
 }
 
 func (u *unmarshalGen) gBase(b *BaseElem) {
@@ -113,11 +137,17 @@ func (u *unmarshalGen) gBase(b *BaseElem) {
 		return
 	}
 
+	if b.Value == Codec {
+		u.p.printf("\n%s, bts, err = %s(bts)", b.Varname(), b.CodecFunc("Unmarshal"))
+		u.p.print(errcheck)
+		return
+	}
+
 	refname := b.Varname() // assigned to
 	lowered := b.Varname() // passed as argument
 	if b.Convert {
 		// begin 'tmp' block
-		refname = randIdent()
+		refname = u.p.ident()
 		lowered = b.ToBase() + "(" + lowered + ")"
 		u.p.printf("\n{\nvar %s %s", refname, b.BaseType())
 	}
@@ -125,8 +155,12 @@ func (u *unmarshalGen) gBase(b *BaseElem) {
 	switch b.Value {
 	case Bytes:
 		u.p.printf("\n%s, bts, err = msgp.ReadBytesBytes(bts, %s)", refname, lowered)
-	case Ext:
-		u.p.printf("\nbts, err = msgp.ReadExtensionBytes(bts, %s)", lowered)
+	case Ext, BigInt, BigFloat, BigRat:
+		u.p.printf("\nbts, err = msgp.Read%sBytes(bts, %s)", b.BaseName(), lowered)
+	case BinaryMarshaler:
+		u.p.printf("\nbts, err = msgp.ReadBinaryUnmarshalerBytes(bts, %s)", lowered)
+	case TextMarshaler:
+		u.p.printf("\nbts, err = msgp.ReadTextUnmarshalerBytes(bts, %s)", lowered)
 	case IDENT:
 		u.p.printf("\nbts, err = %s.UnmarshalMsg(bts)", lowered)
 	default:
@@ -136,7 +170,7 @@ func (u *unmarshalGen) gBase(b *BaseElem) {
 
 	if b.Convert {
 		// close 'tmp' block
-		if b.ShimMode == Cast {
+		if !b.ShimMode.fromBaseErr() {
 			u.p.printf("\n%s = %s(%s)\n", b.Varname(), b.FromBase(), refname)
 		} else {
 			u.p.printf("\n%s, err = %s(%s)", b.Varname(), b.FromBase(), refname)
@@ -153,13 +187,13 @@ func (u *unmarshalGen) gArray(a *Array) {
 
 	// special case for [const]byte objects
 	// see decode.go for symmetry
-	if be, ok := a.Els.(*BaseElem); ok && be.Value == Byte {
+	if isByteArray(a) {
 		u.p.printf("\nbts, err = msgp.ReadExactBytes(bts, (%s)[:])", a.Varname())
 		u.p.print(errcheck)
 		return
 	}
 
-	sz := randIdent()
+	sz := u.p.ident()
 	u.p.declare(sz, u32)
 	u.assignAndCheck(sz, arrayHeader)
 	u.p.arrayCheck(coerceArraySize(a.Size), sz)
@@ -170,7 +204,7 @@ func (u *unmarshalGen) gSlice(s *Slice) {
 	if !u.p.ok() {
 		return
 	}
-	sz := randIdent()
+	sz := u.p.ident()
 	u.p.declare(sz, u32)
 	u.assignAndCheck(sz, arrayHeader)
 	u.p.resizeSlice(sz, s)
@@ -181,7 +215,7 @@ func (u *unmarshalGen) gMap(m *Map) {
 	if !u.p.ok() {
 		return
 	}
-	sz := randIdent()
+	sz := u.p.ident()
 	u.p.declare(sz, u32)
 	u.assignAndCheck(sz, mapHeader)
 
@@ -197,6 +231,40 @@ func (u *unmarshalGen) gMap(m *Map) {
 	u.p.closeblock()
 }
 
+func (u *unmarshalGen) gUnion(un *Union) {
+	if !u.p.ok() {
+		return
+	}
+	u.p.printf("\nif msgp.IsNil(bts) { bts, err = msgp.ReadNilBytes(bts); if err != nil { return }; %s = nil; } else { ", un.Varname())
+	sz := u.p.ident()
+	u.p.declare(sz, u32)
+	u.assignAndCheck(sz, arrayHeader)
+	u.p.arrayCheck("2", sz)
+	tag := u.p.ident()
+	u.p.declare(tag, "[]byte")
+	u.p.printf("\n%s, bts, err = msgp.ReadMapKeyZC(bts)", tag)
+	u.p.print(errcheck)
+	u.p.printf("\nswitch msgp.UnsafeString(%s) {", tag)
+	for i := range un.Variants {
+		if !u.p.ok() {
+			return
+		}
+		v := &un.Variants[i]
+		vn := u.p.ident()
+		u.p.printf("\ncase %q:", v.Tag)
+		u.p.declare(vn, v.Type.TypeName())
+		v.Type.setVarname(vn, u.p.ids)
+		next(u, v.Type)
+		u.p.printf("\n%s = %s", un.Varname(), vn)
+	}
+	// skip the value so that the error is resumable
+	u.p.print("\ndefault:\nbts, err = msgp.Skip(bts)")
+	u.p.print(errcheck)
+	u.p.printf("\no = bts\nerr = msgp.UnionError{Union: %q, Tag: string(%s)}\nreturn", un.TypeName(), tag)
+	u.p.closeblock() // close switch
+	u.p.closeblock() // close else
+}
+
 func (u *unmarshalGen) gPtr(p *Ptr) {
 	u.p.printf("\nif msgp.IsNil(bts) { bts, err = msgp.ReadNilBytes(bts); if err != nil { return }; %s = nil; } else { ", p.Varname())
 	u.p.initPtr(p)
@@ -17,6 +17,9 @@
 //  -io = satisfy the `msgp.Decodable` and `msgp.Encodable` interfaces (default is true)
 //  -marshal = satisfy the `msgp.Marshaler` and `msgp.Unmarshaler` interfaces (default is true)
 //  -tests = generate tests and benchmarks (default is true)
+//  -strict = fail on fields that can't be serialized and unresolved identifiers (default is false)
+//  -tag = struct tag to read for fields without a msg tag, e.g. json (default is none)
+//  -check = don't write files; exit non-zero if the generated files are out of date (default is false)
 //
 // For more information, please read README.md, and the wiki at github.com/tinylib/msgp
 //
@@ -25,13 +28,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/tinylib/msgp/gen"
+	"github.com/tinylib/msgp/msgpgen"
 	"github.com/tinylib/msgp/parse"
-	"github.com/tinylib/msgp/printer"
 	"github.com/ttacon/chalk"
 )
 
@@ -42,6 +44,9 @@ var (
 	marshal    = flag.Bool("marshal", true, "create Marshal and Unmarshal methods")
 	tests      = flag.Bool("tests", true, "create tests and benchmarks")
 	unexported = flag.Bool("unexported", false, "also process unexported types")
+	strict     = flag.Bool("strict", false, "fail on fields that can't be serialized and unresolved identifiers")
+	tag        = flag.String("tag", "", "struct tag to read for fields without a msg tag")
+	check      = flag.Bool("check", false, "don't write files; fail if the generated files are out of date")
 )
 
 func main() {
@@ -72,7 +77,11 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := Run(*file, mode, *unexported); err != nil {
+	run := Run
+	if *check {
+		run = Check
+	}
+	if err := run(*file, mode, *unexported, *strict); err != nil {
 		fmt.Println(chalk.Red.Color(err.Error()))
 		os.Exit(1)
 	}
@@ -80,40 +89,89 @@ func main() {
 
 // Run writes all methods using the associated file or path, e.g.
 //
-//	err := msgp.Run("path/to/myfile.go", gen.Size|gen.Marshal|gen.Unmarshal|gen.Test, false)
+//	err := msgp.Run("path/to/myfile.go", gen.Size|gen.Marshal|gen.Unmarshal|gen.Test, false, false)
 //
-func Run(gofile string, mode gen.Method, unexported bool) error {
+func Run(gofile string, mode gen.Method, unexported bool, strict bool) error {
 	if mode&^gen.Test == 0 {
 		return nil
 	}
 	fmt.Println(chalk.Magenta.Color("======== MessagePack Code Generator ======="))
 	fmt.Printf(chalk.Magenta.Color(">>> Input: \"%s\"\n"), gofile)
-	fs, err := parse.File(gofile, unexported)
+	res, _, err := msgpgen.Generate(msgpgen.Config{
+		File:       gofile,
+		Out:        *out,
+		Mode:       mode,
+		Unexported: unexported,
+		Strict:     strict,
+		Tag:        *tag,
+		Report:     printDiagnostic,
+	})
 	if err != nil {
 		return err
 	}
-
-	if len(fs.Identities) == 0 {
-		fmt.Println(chalk.Magenta.Color("No types requiring code generation were found!"))
+	if res == nil {
 		return nil
 	}
 
-	return printer.PrintFile(newFilename(gofile, fs.Package), fs, mode)
+	if res.Tests != nil {
+		if err := ioutil.WriteFile(res.TestFile, res.Tests, 0600); err != nil {
+			return err
+		}
+		fmt.Printf(chalk.Magenta.Color(">>> Wrote and formatted \"%s\"\n"), res.TestFile)
+	}
+	if err := ioutil.WriteFile(res.File, res.Source, 0600); err != nil {
+		return err
+	}
+	fmt.Printf(chalk.Magenta.Color(">>> Wrote and formatted \"%s\"\n"), res.File)
+	return nil
 }
 
-// picks a new file name based on input flags and input filename(s).
-func newFilename(old string, pkg string) string {
-	if *out != "" {
-		if pre := strings.TrimPrefix(*out, old); len(pre) > 0 &&
-			!strings.HasSuffix(*out, ".go") {
-			return filepath.Join(old, *out)
-		}
-		return *out
+// Check generates all methods in memory, like Run,
+// and returns an error if the files on disk differ.
+func Check(gofile string, mode gen.Method, unexported bool, strict bool) error {
+	if mode&^gen.Test == 0 {
+		return nil
+	}
+	res, _, err := msgpgen.Generate(msgpgen.Config{
+		File:       gofile,
+		Out:        *out,
+		Mode:       mode,
+		Unexported: unexported,
+		Strict:     strict,
+		Tag:        *tag,
+		Report:     printWarning,
+	})
+	if err != nil || res == nil {
+		return err
 	}
+	stale, err := res.Check()
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	for _, s := range stale {
+		fmt.Println(chalk.Yellow.Color(s.String()))
+	}
+	return fmt.Errorf("%d generated file(s) are out of date; run msgp to regenerate", len(stale))
+}
+
+// printWarning prints only warning diagnostics
+func printWarning(d msgpgen.Diagnostic) {
+	if d.Severity == parse.Warning {
+		printDiagnostic(d)
+	}
+}
 
-	if fi, err := os.Stat(old); err == nil && fi.IsDir() {
-		old = filepath.Join(old, pkg)
+// printDiagnostic prints info and warning diagnostics
+// as they are produced. (Errors are also returned
+// from msgpgen.Generate, and printed by main.)
+func printDiagnostic(d msgpgen.Diagnostic) {
+	switch d.Severity {
+	case parse.Info:
+		fmt.Println(chalk.Green.Color(d.String()))
+	case parse.Warning:
+		fmt.Println(chalk.Yellow.Color(d.String()))
 	}
-	// new file name is old file name + _gen.go
-	return strings.TrimSuffix(old, ".go") + "_gen.go"
 }
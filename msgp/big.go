@@ -0,0 +1,146 @@
+package msgp
+
+import (
+	// 'big' is already taken by
+	// binary.BigEndian in this package
+	mathbig "math/big"
+)
+
+// Arbitrary-precision numbers from math/big
+// are encoded as MessagePack 'str' objects
+// holding their text representation, so that
+// they can be read by other implementations
+// and rendered as JSON without loss.
+//
+// *big.Int is written in base 10,
+// *big.Float is written in the shortest 'g'
+// format that round-trips at its precision, and
+// *big.Rat is written as "a/b" (or "a" if it is an
+// integer). When a *big.Float is read, its precision
+// is set to 64 if it is zero; otherwise it is preserved.
+
+// WriteBigInt writes a *big.Int to the writer
+func (mw *Writer) WriteBigInt(z *mathbig.Int) error {
+	return mw.WriteString(z.String())
+}
+
+// WriteBigFloat writes a *big.Float to the writer
+func (mw *Writer) WriteBigFloat(z *mathbig.Float) error {
+	return mw.WriteString(z.Text('g', -1))
+}
+
+// WriteBigRat writes a *big.Rat to the writer
+func (mw *Writer) WriteBigRat(z *mathbig.Rat) error {
+	return mw.WriteString(z.RatString())
+}
+
+// ReadBigInt reads a *big.Int from the reader into z
+func (m *Reader) ReadBigInt(z *mathbig.Int) (err error) {
+	m.scratch, err = m.ReadStringAsBytes(m.scratch[:0])
+	if err != nil {
+		return
+	}
+	return z.UnmarshalText(m.scratch)
+}
+
+// ReadBigFloat reads a *big.Float from the reader into z
+func (m *Reader) ReadBigFloat(z *mathbig.Float) (err error) {
+	m.scratch, err = m.ReadStringAsBytes(m.scratch[:0])
+	if err != nil {
+		return
+	}
+	return z.UnmarshalText(m.scratch)
+}
+
+// ReadBigRat reads a *big.Rat from the reader into z
+func (m *Reader) ReadBigRat(z *mathbig.Rat) (err error) {
+	m.scratch, err = m.ReadStringAsBytes(m.scratch[:0])
+	if err != nil {
+		return
+	}
+	return z.UnmarshalText(m.scratch)
+}
+
+// AppendBigInt appends a *big.Int to the slice
+func AppendBigInt(b []byte, z *mathbig.Int) []byte {
+	return AppendString(b, z.String())
+}
+
+// AppendBigFloat appends a *big.Float to the slice
+func AppendBigFloat(b []byte, z *mathbig.Float) []byte {
+	return AppendString(b, z.Text('g', -1))
+}
+
+// AppendBigRat appends a *big.Rat to the slice
+func AppendBigRat(b []byte, z *mathbig.Rat) []byte {
+	return AppendString(b, z.RatString())
+}
+
+// ReadBigIntBytes reads a *big.Int from 'b'
+// into z and returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not 'str')
+// - a parse error from (*big.Int).UnmarshalText
+func ReadBigIntBytes(b []byte, z *mathbig.Int) ([]byte, error) {
+	v, o, err := ReadStringZC(b)
+	if err != nil {
+		return b, err
+	}
+	return o, z.UnmarshalText(v)
+}
+
+// ReadBigFloatBytes reads a *big.Float from 'b'
+// into z and returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not 'str')
+// - a parse error from (*big.Float).UnmarshalText
+func ReadBigFloatBytes(b []byte, z *mathbig.Float) ([]byte, error) {
+	v, o, err := ReadStringZC(b)
+	if err != nil {
+		return b, err
+	}
+	return o, z.UnmarshalText(v)
+}
+
+// ReadBigRatBytes reads a *big.Rat from 'b'
+// into z and returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not 'str')
+// - a parse error from (*big.Rat).UnmarshalText
+func ReadBigRatBytes(b []byte, z *mathbig.Rat) ([]byte, error) {
+	v, o, err := ReadStringZC(b)
+	if err != nil {
+		return b, err
+	}
+	return o, z.UnmarshalText(v)
+}
+
+// decimalDigits returns an upper bound on the
+// number of decimal digits in an n-bit number
+// (1234/4096 is slightly more than log10(2))
+func decimalDigits(n int) int {
+	return (n*1234)>>12 + 1
+}
+
+// BigIntSize returns the maximum
+// encoded size of z
+func BigIntSize(z *mathbig.Int) int {
+	return StringPrefixSize + 1 + decimalDigits(z.BitLen())
+}
+
+// BigFloatSize returns the maximum
+// encoded size of z
+func BigFloatSize(z *mathbig.Float) int {
+	// sign, decimal point, exponent,
+	// and some slack for the 'g' format
+	return StringPrefixSize + 24 + decimalDigits(int(z.Prec()))
+}
+
+// BigRatSize returns the maximum
+// encoded size of z
+func BigRatSize(z *mathbig.Rat) int {
+	return StringPrefixSize + 2 + decimalDigits(z.Num().BitLen()) + decimalDigits(z.Denom().BitLen())
+}
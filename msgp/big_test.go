@@ -0,0 +1,77 @@
+package msgp
+
+import (
+	"bytes"
+	mathbig "math/big"
+	"testing"
+)
+
+func TestReadWriteBig(t *testing.T) {
+	ints := []string{"0", "1", "-1", "18446744073709551616", "-123456789012345678901234567890"}
+	var buf bytes.Buffer
+	en := NewWriter(&buf)
+	dc := NewReader(&buf)
+	for _, s := range ints {
+		in, _ := new(mathbig.Int).SetString(s, 10)
+		buf.Reset()
+		if err := en.WriteBigInt(in); err != nil {
+			t.Fatal(err)
+		}
+		en.Flush()
+		if buf.Len() > BigIntSize(in) {
+			t.Errorf("%s: BigIntSize() = %d; encoded %d bytes", s, BigIntSize(in), buf.Len())
+		}
+		b := AppendBigInt(nil, in)
+		if !bytes.Equal(b, buf.Bytes()) {
+			t.Errorf("%s: AppendBigInt and WriteBigInt disagree", s)
+		}
+
+		out := new(mathbig.Int)
+		if err := dc.ReadBigInt(out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Cmp(in) != 0 {
+			t.Errorf("got %s; want %s", out, in)
+		}
+		out = new(mathbig.Int)
+		if b, err := ReadBigIntBytes(b, out); err != nil || len(b) != 0 {
+			t.Fatal(err, len(b))
+		}
+		if out.Cmp(in) != 0 {
+			t.Errorf("got %s; want %s", out, in)
+		}
+	}
+
+	f := new(mathbig.Float).SetPrec(300)
+	f.SetString("3.14159265358979323846264338327950288419716939937510582097494459")
+	b := AppendBigFloat(nil, f)
+	if len(b) > BigFloatSize(f) {
+		t.Errorf("BigFloatSize() = %d; encoded %d bytes", BigFloatSize(f), len(b))
+	}
+	fout := new(mathbig.Float).SetPrec(300)
+	if _, err := ReadBigFloatBytes(b, fout); err != nil {
+		t.Fatal(err)
+	}
+	if fout.Cmp(f) != 0 {
+		t.Errorf("got %s; want %s", fout, f)
+	}
+
+	r := mathbig.NewRat(-22, 7)
+	b = AppendBigRat(nil, r)
+	if len(b) > BigRatSize(r) {
+		t.Errorf("BigRatSize() = %d; encoded %d bytes", BigRatSize(r), len(b))
+	}
+	rout := new(mathbig.Rat)
+	if _, err := ReadBigRatBytes(b, rout); err != nil {
+		t.Fatal(err)
+	}
+	if rout.Cmp(r) != 0 {
+		t.Errorf("got %s; want %s", rout, r)
+	}
+
+	// not a number
+	b = AppendString(nil, "pi")
+	if _, err := ReadBigIntBytes(b, new(mathbig.Int)); err == nil {
+		t.Error("expected a parse error")
+	}
+}
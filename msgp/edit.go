@@ -2,6 +2,7 @@ package msgp
 
 import (
 	"math"
+	"strconv"
 )
 
 // Locate returns a []byte pointing to the field
@@ -13,6 +14,54 @@ func Locate(key string, raw []byte) []byte {
 	return raw[s:n]
 }
 
+// LocatePath returns a []byte pointing to the object
+// at 'path' in 'raw', where each element of the path
+// is either the key of a field in a map or, for an
+// array, the decimal index of an element. For example,
+// LocatePath(raw, "users", "3", "name") returns the
+// "name" field of the fourth element of the "users"
+// array. Like Locate, it does no allocations, and it
+// returns a zero-length []byte if the path doesn't exist.
+// An empty path returns the first object in 'raw'.
+func LocatePath(raw []byte, path ...string) []byte {
+	for _, elem := range path {
+		if NextType(raw) == ArrayType {
+			raw = locateIndex(raw, elem)
+		} else {
+			raw = Locate(elem, raw)
+		}
+		if len(raw) == 0 {
+			return raw
+		}
+	}
+	tail, err := Skip(raw)
+	if err != nil {
+		return raw[:0]
+	}
+	return raw[:len(raw)-len(tail)]
+}
+
+// locateIndex returns the element of the array
+// in 'raw' at the index in 'elem', and any bytes
+// that follow it
+func locateIndex(raw []byte, elem string) []byte {
+	i, err := strconv.ParseUint(elem, 10, 32)
+	if err != nil {
+		return raw[:0]
+	}
+	sz, bts, err := ReadArrayHeaderBytes(raw)
+	if err != nil || uint32(i) >= sz {
+		return raw[:0]
+	}
+	for ; i > 0; i-- {
+		bts, err = Skip(bts)
+		if err != nil {
+			return raw[:0]
+		}
+	}
+	return bts
+}
+
 // Replace takes a key ("key") in a messagepack map ("raw")
 // and replaces its value with the one provided and returns
 // the new []byte. The returned []byte may point to the same
@@ -11,6 +11,11 @@ var (
 	// contain the contents of the message
 	ErrShortBytes error = errShort{}
 
+	// ErrHeaderFlushed is returned by (*Writer).FillHeader
+	// when the reserved header has already been flushed
+	// to a writer that can't be patched in place
+	ErrHeaderFlushed error = errHeaderFlushed{}
+
 	// this error is only returned
 	// if we reach code that should
 	// be unreachable
@@ -35,6 +40,13 @@ type errShort struct{}
 func (e errShort) Error() string   { return "msgp: too few bytes left to read object" }
 func (e errShort) Resumable() bool { return false }
 
+type errHeaderFlushed struct{}
+
+func (e errHeaderFlushed) Error() string {
+	return "msgp: reserved header was flushed before its size was filled"
+}
+func (e errHeaderFlushed) Resumable() bool { return false }
+
 type errFatal struct{}
 
 func (f errFatal) Error() string   { return "msgp: fatal decoding error (unreachable code)" }
@@ -140,3 +152,71 @@ func (e *ErrUnsupportedType) Error() string { return fmt.Sprintf("msgp: type %q
 
 // Resumable returns 'true' for ErrUnsupportedType
 func (e *ErrUnsupportedType) Resumable() bool { return true }
+
+// UnionError is returned when a union
+// (an interface field with a fixed set of
+// concrete types) holds a type that isn't
+// part of the union, or when the encoded
+// tag doesn't name one of its types.
+type UnionError struct {
+	Union string       // name of the union type
+	Tag   string       // unknown tag, when decoding
+	Type  reflect.Type // unknown concrete type, when encoding
+}
+
+// Error implements the error interface
+func (u UnionError) Error() string {
+	if u.Type != nil {
+		return fmt.Sprintf("msgp: type %s is not part of union %s", u.Type, u.Union)
+	}
+	return fmt.Sprintf("msgp: unknown tag %q for union %s", u.Tag, u.Union)
+}
+
+// Resumable returns 'true' for UnionErrors
+func (u UnionError) Resumable() bool { return true }
+
+// EnumError is returned when a string-encoded
+// enum doesn't match any of the enum's constants.
+type EnumError struct {
+	Enum  string // name of the enum type
+	Value string // the unknown name
+}
+
+// Error implements the error interface
+func (e EnumError) Error() string {
+	return fmt.Sprintf("msgp: unknown value %q for enum %s", e.Value, e.Enum)
+}
+
+// Resumable returns 'true' for EnumErrors
+func (e EnumError) Resumable() bool { return true }
+
+// NonFiniteError is returned when a NaN or
+// infinite float is translated to JSON, which
+// can't represent it. See JSONOptions.NonFinite.
+type NonFiniteError struct {
+	Value float64
+}
+
+// Error implements the error interface
+func (e NonFiniteError) Error() string {
+	return fmt.Sprintf("msgp: can't represent %v in JSON", e.Value)
+}
+
+// Resumable returns 'true' for NonFiniteErrors
+func (e NonFiniteError) Resumable() bool { return true }
+
+// FrameError is returned by a FrameReader
+// when a frame is corrupt. Call Resync to
+// continue reading at the next frame.
+type FrameError struct {
+	Offset int64  // offset of the frame in the stream
+	Reason string // what is wrong with it
+}
+
+// Error implements the error interface
+func (e FrameError) Error() string {
+	return fmt.Sprintf("msgp: bad frame at offset %d: %s", e.Offset, e.Reason)
+}
+
+// Resumable returns 'false' for FrameErrors
+func (e FrameError) Resumable() bool { return false }
@@ -3,6 +3,7 @@ package msgp
 import (
 	"fmt"
 	"math"
+	"sync"
 )
 
 const (
@@ -16,11 +17,71 @@ const (
 	TimeExtension = 5
 )
 
-// our extensions live here
-var extensionReg = make(map[int8]func() Extension)
+// An ExtensionRegistry maps extension numbers
+// to constructors for the types that implement
+// them. Registries are used to decode extensions
+// into `interface{}` values and to translate them
+// to JSON. An ExtensionRegistry is safe for
+// concurrent use.
+type ExtensionRegistry struct {
+	parent *ExtensionRegistry
+	mu     sync.RWMutex
+	reg    map[int8]func() Extension
+}
+
+// DefaultExtensions is the registry used by
+// RegisterExtension and by every Reader that
+// does not have a registry of its own.
+var DefaultExtensions = NewExtensionRegistry(nil)
+
+// NewExtensionRegistry returns an empty registry.
+// Extension numbers that are not registered in
+// the new registry are looked up in 'parent',
+// if it is non-nil, so a registry created with
+// DefaultExtensions as its parent only needs to
+// hold the types it adds or overrides.
+func NewExtensionRegistry(parent *ExtensionRegistry) *ExtensionRegistry {
+	return &ExtensionRegistry{
+		parent: parent,
+		reg:    make(map[int8]func() Extension),
+	}
+}
+
+// Register adds the extension constructor 'f' for
+// the extension number 'typ'. It returns an error if
+// 'typ' is reserved or if it has already been registered
+// in this registry. (Numbers registered in the parent
+// registry can be overridden.)
+func (e *ExtensionRegistry) Register(typ int8, f func() Extension) error {
+	switch typ {
+	case Complex64Extension, Complex128Extension, TimeExtension:
+		return fmt.Errorf("msgp: forbidden extension type: %d", typ)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.reg[typ]; ok {
+		return fmt.Errorf("msgp: extension type %d registered more than once", typ)
+	}
+	e.reg[typ] = f
+	return nil
+}
+
+// Lookup returns the constructor for the extension
+// number 'typ', if there is one.
+func (e *ExtensionRegistry) Lookup(typ int8) (f func() Extension, ok bool) {
+	for r := e; r != nil; r = r.parent {
+		r.mu.RLock()
+		f, ok = r.reg[typ]
+		r.mu.RUnlock()
+		if ok {
+			return
+		}
+	}
+	return nil, false
+}
 
-// RegisterExtension registers extensions so that they
-// can be initialized and returned by methods that
+// RegisterExtension registers extensions in DefaultExtensions
+// so that they can be initialized and returned by methods that
 // decode `interface{}` values. This should only
 // be called during initialization. f() should return
 // a newly-initialized zero value of the extension. Keep in
@@ -34,16 +95,13 @@ var extensionReg = make(map[int8]func() Extension)
 //
 // RegisterExtension will panic if you call it multiple times
 // with the same 'typ' argument, or if you use a reserved
-// type (3, 4, or 5).
+// type (3, 4, or 5). Libraries that may share an extension
+// number with other code should use an ExtensionRegistry
+// of their own instead.
 func RegisterExtension(typ int8, f func() Extension) {
-	switch typ {
-	case Complex64Extension, Complex128Extension, TimeExtension:
-		panic(fmt.Sprint("msgp: forbidden extension type:", typ))
-	}
-	if _, ok := extensionReg[typ]; ok {
-		panic(fmt.Sprint("msgp: RegisterExtension() called with typ", typ, "more than once"))
+	if err := DefaultExtensions.Register(typ, f); err != nil {
+		panic(err)
 	}
-	extensionReg[typ] = f
 }
 
 // ExtensionTypeError is an error type returned
@@ -89,6 +147,75 @@ type Extension interface {
 	UnmarshalBinary([]byte) error
 }
 
+// An ExtensionAppender is an Extension that
+// can append its data to a slice without
+// knowing its length in advance. AppendExtension
+// and WriteExtension use AppendExtensionData
+// instead of Len and MarshalBinaryTo when it
+// is available, so that extensions whose Len
+// requires encoding the data (like the ones
+// generated for //msgp:extension) are only
+// encoded once.
+type ExtensionAppender interface {
+	Extension
+
+	// AppendExtensionData should append
+	// the data to the supplied slice and
+	// return the extended slice
+	AppendExtensionData([]byte) ([]byte, error)
+}
+
+// ExtensionSize returns an upper bound for
+// the encoded size of e, including its header.
+// If e implements Sizer, it uses Msgsize, so that
+// extensions whose Len requires encoding the data
+// are not encoded in order to compute it.
+func ExtensionSize(e Extension) int {
+	if s, ok := e.(Sizer); ok {
+		return ExtensionPrefixSize + s.Msgsize()
+	}
+	return ExtensionPrefixSize + e.Len()
+}
+
+// putExtHeader writes the header of an extension
+// of type 'typ' with 'l' bytes of data into 'b', which
+// must have room for ExtensionPrefixSize bytes, and returns
+// the number of bytes written
+func putExtHeader(b []byte, typ int8, l int) int {
+	switch l {
+	case 1:
+		b[0] = mfixext1
+	case 2:
+		b[0] = mfixext2
+	case 4:
+		b[0] = mfixext4
+	case 8:
+		b[0] = mfixext8
+	case 16:
+		b[0] = mfixext16
+	default:
+		switch {
+		case l < math.MaxUint8:
+			b[0] = mext8
+			b[1] = byte(uint8(l))
+			b[2] = byte(typ)
+			return 3
+		case l < math.MaxUint16:
+			b[0] = mext16
+			big.PutUint16(b[1:], uint16(l))
+			b[3] = byte(typ)
+			return 4
+		default:
+			b[0] = mext32
+			big.PutUint32(b[1:], uint32(l))
+			b[5] = byte(typ)
+			return 6
+		}
+	}
+	b[1] = byte(typ)
+	return 2
+}
+
 // RawExtension implements the Extension interface
 type RawExtension struct {
 	Data []byte
@@ -122,6 +249,9 @@ func (r *RawExtension) UnmarshalBinary(b []byte) error {
 
 // WriteExtension writes an extension type to the writer
 func (mw *Writer) WriteExtension(e Extension) error {
+	if ea, ok := e.(ExtensionAppender); ok {
+		return mw.writeExtensionData(ea)
+	}
 	l := e.Len()
 	var err error
 	switch l {
@@ -223,6 +353,22 @@ func (mw *Writer) WriteExtension(e Extension) error {
 	return nil
 }
 
+// writeExtensionData writes an ExtensionAppender,
+// encoding its data before the header
+func (mw *Writer) writeExtensionData(e ExtensionAppender) error {
+	data, err := e.AppendExtensionData(nil)
+	if err != nil {
+		return err
+	}
+	var hdr [ExtensionPrefixSize]byte
+	n := putExtHeader(hdr[:], e.ExtensionType(), len(data))
+	if err = mw.Append(hdr[:n]...); err != nil {
+		return err
+	}
+	_, err = mw.Write(data)
+	return err
+}
+
 // peek at the extension type, assuming the next
 // kind to be read is Extension
 func (m *Reader) peekExtensionType() (int8, error) {
@@ -410,6 +556,9 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 
 // AppendExtension appends a MessagePack extension to the provided slice
 func AppendExtension(b []byte, e Extension) ([]byte, error) {
+	if ea, ok := e.(ExtensionAppender); ok {
+		return appendExtensionData(b, ea)
+	}
 	l := e.Len()
 	var o []byte
 	var n int
@@ -469,6 +618,21 @@ func AppendExtension(b []byte, e Extension) ([]byte, error) {
 	return o, e.MarshalBinaryTo(o[n:])
 }
 
+// appendExtensionData appends an ExtensionAppender
+// after room for the largest header, then moves
+// the data down to fit the header it actually needs
+func appendExtensionData(b []byte, e ExtensionAppender) ([]byte, error) {
+	n := len(b)
+	o, err := e.AppendExtensionData(append(b, make([]byte, ExtensionPrefixSize)...))
+	if err != nil {
+		return b, err
+	}
+	l := len(o) - n - ExtensionPrefixSize
+	h := putExtHeader(o[n:], e.ExtensionType(), l)
+	copy(o[n+h:], o[n+ExtensionPrefixSize:])
+	return o[:n+h+l], nil
+}
+
 // ReadExtensionBytes reads an extension from 'b' into 'e'
 // and returns any remaining bytes.
 // Possible errors:
@@ -47,3 +47,173 @@ func TestReadWriteExtensionBytes(t *testing.T) {
 		}
 	}
 }
+
+// testExt is an Extension whose type number
+// is chosen by the registry that creates it
+type testExt struct {
+	Typ  int8
+	Data []byte
+	From string
+}
+
+func (t *testExt) ExtensionType() int8            { return t.Typ }
+func (t *testExt) Len() int                       { return len(t.Data) }
+func (t *testExt) MarshalBinaryTo(b []byte) error { copy(b, t.Data); return nil }
+func (t *testExt) UnmarshalBinary(b []byte) error { t.Data = append(t.Data[:0], b...); return nil }
+func newTestExt(typ int8, from string) func() Extension {
+	return func() Extension { return &testExt{Typ: typ, From: from} }
+}
+
+func TestExtensionRegistry(t *testing.T) {
+	parent := NewExtensionRegistry(nil)
+	if err := parent.Register(40, newTestExt(40, "parent")); err != nil {
+		t.Fatal(err)
+	}
+	if err := parent.Register(41, newTestExt(41, "parent")); err != nil {
+		t.Fatal(err)
+	}
+	if err := parent.Register(40, newTestExt(40, "parent")); err == nil {
+		t.Error("expected an error registering 40 twice")
+	}
+	if err := parent.Register(TimeExtension, newTestExt(TimeExtension, "parent")); err == nil {
+		t.Error("expected an error registering a reserved extension")
+	}
+
+	child := NewExtensionRegistry(parent)
+	if err := child.Register(40, newTestExt(40, "child")); err != nil {
+		t.Fatalf("overriding a parent extension: %s", err)
+	}
+	for _, tc := range []struct {
+		typ  int8
+		from string
+	}{{40, "child"}, {41, "parent"}, {42, ""}} {
+		f, ok := child.Lookup(tc.typ)
+		if !ok {
+			if tc.from != "" {
+				t.Errorf("Lookup(%d): not found", tc.typ)
+			}
+			continue
+		}
+		if from := f().(*testExt).From; from != tc.from {
+			t.Errorf("Lookup(%d): got %q; want %q", tc.typ, from, tc.from)
+		}
+	}
+	if _, ok := DefaultExtensions.Lookup(40); ok {
+		t.Error("scoped registration leaked into DefaultExtensions")
+	}
+}
+
+func TestReaderExtensions(t *testing.T) {
+	reg := NewExtensionRegistry(DefaultExtensions)
+	reg.Register(40, newTestExt(40, "reg"))
+
+	bts, _ := AppendIntf(nil, []interface{}{&RawExtension{Type: 40, Data: []byte("hi")}})
+
+	// default registry: raw extension
+	v, _, err := ReadIntfBytes(bts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.([]interface{})[0].(*RawExtension); !ok {
+		t.Errorf("ReadIntfBytes: got %T; want *RawExtension", v.([]interface{})[0])
+	}
+
+	v, _, err = reg.ReadIntfBytes(bts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := v.([]interface{})[0].(*testExt); !ok || string(x.Data) != "hi" {
+		t.Errorf("ExtensionRegistry.ReadIntfBytes: got %#v", v)
+	}
+
+	rd := NewReader(bytes.NewReader(bts))
+	rd.Extensions = reg
+	v, err = rd.ReadIntf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := v.([]interface{})[0].(*testExt); !ok || string(x.Data) != "hi" {
+		t.Errorf("Reader.ReadIntf: got %#v", v)
+	}
+
+	var js bytes.Buffer
+	if _, err := reg.UnmarshalAsJSON(&js, bts); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"Typ":40,"Data":"aGk=","From":"reg"}]`
+	if js.String() != want {
+		t.Errorf("UnmarshalAsJSON: got %s; want %s", js.String(), want)
+	}
+
+	js.Reset()
+	rd = NewReader(bytes.NewReader(bts))
+	rd.Extensions = reg
+	if _, err := rd.WriteToJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	if js.String() != want {
+		t.Errorf("WriteToJSON: got %s; want %s", js.String(), want)
+	}
+}
+
+// appendExt is an ExtensionAppender that
+// cannot be written with Len and MarshalBinaryTo
+type appendExt struct{ RawExtension }
+
+func (a *appendExt) Len() int                     { panic("Len called") }
+func (a *appendExt) MarshalBinaryTo([]byte) error { panic("MarshalBinaryTo called") }
+
+func (a *appendExt) AppendExtensionData(b []byte) ([]byte, error) {
+	return append(b, a.Data...), nil
+}
+
+func TestExtensionAppender(t *testing.T) {
+	for _, l := range []int{0, 1, 2, 3, 4, 8, 16, 100, 254, 255, 256, 70000} {
+		raw := &RawExtension{Type: 42, Data: make([]byte, l)}
+		rand.Read(raw.Data)
+		var want bytes.Buffer
+		en := NewWriter(&want)
+		en.WriteNil()
+		if err := en.WriteExtension(raw); err != nil {
+			t.Fatal(err)
+		}
+		en.Flush()
+
+		ext := &appendExt{*raw}
+		var buf bytes.Buffer
+		en = NewWriter(&buf)
+		en.WriteNil()
+		if err := en.WriteExtension(ext); err != nil {
+			t.Fatal(err)
+		}
+		en.Flush()
+		if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+			t.Errorf("WriteExtension with %d bytes: encodings differ", l)
+		}
+
+		got, err := AppendExtension([]byte{0xc0}, ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("AppendExtension with %d bytes: encodings differ", l)
+		}
+	}
+}
+
+// sizedExt is an appendExt that knows its size
+type sizedExt struct{ appendExt }
+
+func (s *sizedExt) Msgsize() int { return len(s.Data) + 1 }
+
+func TestExtensionSize(t *testing.T) {
+	raw := &RawExtension{Type: 42, Data: make([]byte, 20)}
+	if got, want := ExtensionSize(raw), ExtensionPrefixSize+20; got != want {
+		t.Errorf("ExtensionSize(RawExtension) = %d; want %d", got, want)
+	}
+	// Len panics, so Msgsize must be used
+	s := &sizedExt{appendExt{*raw}}
+	if got, want := ExtensionSize(s), ExtensionPrefixSize+21; got != want {
+		t.Errorf("ExtensionSize(Sizer) = %d; want %d", got, want)
+	}
+}
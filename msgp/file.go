@@ -4,6 +4,7 @@
 package msgp
 
 import (
+	"fmt"
 	"os"
 	"syscall"
 )
@@ -26,25 +27,35 @@ func ReadFile(dst Unmarshaler, file *os.File) error {
 	if err != nil {
 		return err
 	}
-	data, err := syscall.Mmap(int(file.Fd()), 0, int(stat.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
-	if err != nil {
+	return readMapped(file, stat.Size(), func(data []byte) error {
+		_, err := dst.UnmarshalMsg(data)
 		return err
+	})
+}
+
+// mapFile returns a read-only memory
+// mapping of the first 'size' bytes of 'file'
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	if size == 0 {
+		return nil, nil
 	}
-	adviseRead(data)
-	_, err = dst.UnmarshalMsg(data)
-	uerr := syscall.Munmap(data)
-	if err == nil {
-		err = uerr
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("msgp: %s is too large to map", file.Name())
 	}
-	return err
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	adviseRead(data)
+	return data, nil
 }
 
-// MarshalSizer is the combination
-// of the Marshaler and Sizer
-// interfaces.
-type MarshalSizer interface {
-	Marshaler
-	Sizer
+// unmapFile releases a mapping from mapFile
+func unmapFile(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return syscall.Munmap(data)
 }
 
 // WriteFile writes a file from 'src' using
@@ -1,20 +1,14 @@
-// +build windows appengine
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd) || appengine
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd appengine
 
 package msgp
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 )
 
-// MarshalSizer is the combination
-// of the Marshaler and Sizer
-// interfaces.
-type MarshalSizer interface {
-	Marshaler
-	Sizer
-}
-
 func ReadFile(dst Unmarshaler, file *os.File) error {
 	if u, ok := dst.(Decodable); ok {
 		return u.DecodeMsg(NewReader(file))
@@ -28,6 +22,22 @@ func ReadFile(dst Unmarshaler, file *os.File) error {
 	return err
 }
 
+// mapFile reads the first 'size'
+// bytes of 'file' into memory, since
+// this platform can't map them
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("msgp: %s is too large to read", file.Name())
+	}
+	data := make([]byte, size)
+	if n, err := file.ReadAt(data, 0); n < len(data) {
+		return nil, err
+	}
+	return data, nil
+}
+
+func unmapFile(data []byte) error { return nil }
+
 func WriteFile(src MarshalSizer, file *os.File) error {
 	if e, ok := src.(Encodable); ok {
 		w := NewWriter(file)
@@ -0,0 +1,402 @@
+package msgp
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// A framed stream is an optional header, followed
+// by frames that each hold one MessagePack object:
+//
+//	header := magic version(1)
+//	frame  := marker(2) length(4) [lcrc(4)] message(length) [crc(4)]
+//
+// The marker is 0xc1 0x7e; 0xc1 is never used by
+// MessagePack, so a marker can't start a message.
+// The length and checksums are big-endian; lcrc is
+// the CRC-32C of the length, and crc is the CRC-32C
+// of the message. Checking the length before reading
+// the message means that a corrupt length can't make
+// the reader swallow the frames that follow it.
+
+// DefaultMaxFrameSize is the largest frame that a
+// FrameReader accepts if FrameOptions.MaxSize is zero.
+const DefaultMaxFrameSize = 64 << 20
+
+var (
+	frameMarker = []byte{0xc1, 0x7e}
+	castagnoli  = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// frameHeaderSize is the size of the marker and length
+const frameHeaderSize = 6
+
+// FrameOptions configure a FrameWriter or
+// FrameReader. Both ends of a stream must
+// use the same Checksum and Magic.
+type FrameOptions struct {
+	// Checksum adds CRC-32Cs of the length and message
+	// to each frame. Without it, corrupt frames are only
+	// detected if their marker is damaged or their length
+	// is too large, and a corrupt length can cause the
+	// frames that follow to be read as its message.
+	Checksum bool
+
+	// Magic, if non-empty, starts the stream,
+	// and is followed by the Version byte.
+	Magic   []byte
+	Version uint8
+
+	// MaxSize is the largest frame that a FrameReader
+	// accepts. If it is zero, DefaultMaxFrameSize is used.
+	MaxSize uint32
+}
+
+// maxFrameMessage is the largest message whose
+// frame size fits in an int, which is smaller
+// than MaxSize can be on 32-bit platforms
+var maxFrameMessage = int(^uint(0)>>1) - (frameHeaderSize + 8)
+
+func (o *FrameOptions) maxSize() uint32 {
+	if o.MaxSize == 0 {
+		return DefaultMaxFrameSize
+	}
+	if uint64(o.MaxSize) > uint64(maxFrameMessage) {
+		return uint32(maxFrameMessage)
+	}
+	return o.MaxSize
+}
+
+// headerSize returns the size of the header of a frame
+func (o *FrameOptions) headerSize() int {
+	if o.Checksum {
+		return frameHeaderSize + 4
+	}
+	return frameHeaderSize
+}
+
+// frameSize returns the size of a frame holding 'sz' bytes
+func (o *FrameOptions) frameSize(sz int) int {
+	if o.Checksum {
+		return o.headerSize() + sz + 4
+	}
+	return o.headerSize() + sz
+}
+
+// frameSum returns the checksum of part of a frame
+func frameSum(p []byte) uint32 {
+	return crc32.Checksum(p, castagnoli)
+}
+
+// FrameWriter writes MessagePack objects
+// as frames. See FrameOptions.
+type FrameWriter struct {
+	w       *Writer
+	opts    FrameOptions
+	started bool // the header has been written
+	buf     bytes.Buffer
+	enc     *Writer // writes to buf
+}
+
+// NewFrameWriter returns a FrameWriter that writes to 'w'.
+// You must call Flush to write all of the buffered frames.
+func NewFrameWriter(w io.Writer, opts FrameOptions) *FrameWriter {
+	return &FrameWriter{w: NewWriter(w), opts: opts}
+}
+
+// start writes the header, if it hasn't been written
+func (f *FrameWriter) start() error {
+	if f.started {
+		return nil
+	}
+	if len(f.opts.Magic) > 0 {
+		if _, err := f.w.Write(f.opts.Magic); err != nil {
+			return err
+		}
+		if err := f.w.Append(f.opts.Version); err != nil {
+			return err
+		}
+	}
+	f.started = true
+	return nil
+}
+
+// WriteFrame writes 'msg', which should
+// be one MessagePack object, as a frame.
+func (f *FrameWriter) WriteFrame(msg []byte) error {
+	if uint64(len(msg)) > math.MaxUint32 {
+		return fmt.Errorf("msgp: message of %d bytes is too large for a frame", len(msg))
+	}
+	if err := f.start(); err != nil {
+		return err
+	}
+	var hdr [frameHeaderSize + 4]byte
+	copy(hdr[:], frameMarker)
+	big.PutUint32(hdr[2:], uint32(len(msg)))
+	if f.opts.Checksum {
+		big.PutUint32(hdr[frameHeaderSize:], frameSum(hdr[2:frameHeaderSize]))
+	}
+	if _, err := f.w.Write(hdr[:f.opts.headerSize()]); err != nil {
+		return err
+	}
+	if _, err := f.w.Write(msg); err != nil {
+		return err
+	}
+	if f.opts.Checksum {
+		var sum [4]byte
+		big.PutUint32(sum[:], frameSum(msg))
+		if _, err := f.w.Write(sum[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encode writes 'e' as a frame.
+func (f *FrameWriter) Encode(e Encodable) error {
+	f.buf.Reset()
+	if f.enc == nil {
+		f.enc = NewWriter(&f.buf)
+	}
+	if err := e.EncodeMsg(f.enc); err != nil {
+		f.enc.Reset(&f.buf)
+		return err
+	}
+	if err := f.enc.Flush(); err != nil {
+		return err
+	}
+	return f.WriteFrame(f.buf.Bytes())
+}
+
+// CopyNext reads the next object from 'r'
+// without decoding it, and writes it as a frame.
+func (f *FrameWriter) CopyNext(r *Reader) error {
+	f.buf.Reset()
+	if _, err := r.CopyNext(&f.buf); err != nil {
+		return err
+	}
+	return f.WriteFrame(f.buf.Bytes())
+}
+
+// Flush writes all of the buffered
+// frames to the underlying writer.
+func (f *FrameWriter) Flush() error { return f.w.Flush() }
+
+// FrameReader reads MessagePack objects
+// from frames. See FrameOptions.
+type FrameReader struct {
+	r       *Reader
+	opts    FrameOptions
+	started bool // the header has been read
+	version uint8
+	off     int64 // offset of the next byte
+	buf     []byte
+	br      bytes.Reader
+	dec     *Reader // reads from br
+}
+
+// NewFrameReader returns a FrameReader that reads from 'r'.
+func NewFrameReader(r io.Reader, opts FrameOptions) *FrameReader {
+	return &FrameReader{r: NewReader(r), opts: opts}
+}
+
+// start reads the header, if it hasn't been read
+func (f *FrameReader) start() error {
+	if f.started || len(f.opts.Magic) == 0 {
+		f.started = true
+		return nil
+	}
+	n := len(f.opts.Magic) + 1
+	p, err := f.r.R.Peek(n)
+	if err != nil {
+		if len(p) > 0 && err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if !bytes.Equal(p[:len(f.opts.Magic)], f.opts.Magic) {
+		return FrameError{Offset: 0, Reason: "bad magic"}
+	}
+	f.version = p[len(f.opts.Magic)]
+	f.r.R.Skip(n)
+	f.off += int64(n)
+	f.started = true
+	return nil
+}
+
+// Version returns the version in the header of
+// the stream, or 0 if FrameOptions.Magic is empty.
+func (f *FrameReader) Version() (uint8, error) {
+	err := f.start()
+	return f.version, err
+}
+
+// Offset returns the offset of the next byte to be
+// read from the stream, counting the header. After a
+// successful call to Next, it is the offset of the next
+// frame, which can be used to truncate a stream after
+// its last good frame; after an error, it counts the
+// bytes of the failed frame that were consumed.
+func (f *FrameReader) Offset() int64 { return f.off }
+
+// header reads the header of the next frame, and
+// returns its length. If the header is corrupt, only
+// the marker is consumed, so that Resync starts
+// looking for the next frame just after it.
+func (f *FrameReader) header() (uint32, error) {
+	if err := f.start(); err != nil {
+		return 0, err
+	}
+	hs := f.opts.headerSize()
+	p, err := f.r.R.Peek(hs)
+	if err != nil {
+		if len(p) == 0 && err == io.EOF {
+			return 0, io.EOF
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if !bytes.Equal(p[:2], frameMarker) {
+		return 0, FrameError{Offset: f.off, Reason: "missing frame marker"}
+	}
+	sz := big.Uint32(p[2:])
+	reason := ""
+	if f.opts.Checksum && big.Uint32(p[frameHeaderSize:]) != frameSum(p[2:frameHeaderSize]) {
+		reason = "length checksum mismatch"
+	} else if sz > f.opts.maxSize() {
+		reason = fmt.Sprintf("length %d is larger than the maximum of %d", sz, f.opts.maxSize())
+	}
+	if reason != "" {
+		f.r.R.Skip(len(frameMarker))
+		f.off += int64(len(frameMarker))
+		return 0, FrameError{Offset: f.off - int64(len(frameMarker)), Reason: reason}
+	}
+	f.r.R.Skip(hs)
+	f.off += int64(hs)
+	return sz, nil
+}
+
+// Next reads the next frame, and returns its message,
+// which is only valid until the next call to the
+// FrameReader. It returns io.EOF at the end of the
+// stream, io.ErrUnexpectedEOF if the last frame is
+// truncated, and a FrameError if the frame is corrupt.
+// If the header of the frame is corrupt, only its marker
+// is consumed; otherwise, the whole frame is.
+func (f *FrameReader) Next() ([]byte, error) {
+	start := f.off
+	sz, err := f.header()
+	if err != nil {
+		return nil, err
+	}
+	n := f.opts.frameSize(int(sz)) - f.opts.headerSize()
+	if cap(f.buf) < n {
+		f.buf = make([]byte, n)
+	}
+	f.buf = f.buf[:n]
+	nn, err := f.r.R.ReadFull(f.buf)
+	f.off += int64(nn)
+	if err != nil {
+		return nil, err
+	}
+	msg := f.buf[:sz]
+	if f.opts.Checksum {
+		if big.Uint32(f.buf[sz:]) != frameSum(msg) {
+			return nil, FrameError{Offset: start, Reason: "checksum mismatch"}
+		}
+	}
+	return msg, nil
+}
+
+// Decode reads the next frame into 'd'.
+// It returns the same errors as Next,
+// in addition to those from d.DecodeMsg.
+func (f *FrameReader) Decode(d Decodable) error {
+	msg, err := f.Next()
+	if err != nil {
+		return err
+	}
+	f.br.Reset(msg)
+	if f.dec == nil {
+		f.dec = NewReader(&f.br)
+	} else {
+		f.dec.Reset(&f.br)
+	}
+	return d.DecodeMsg(f.dec)
+}
+
+// CopyNext reads the next frame, and
+// writes its message to 'w'.
+func (f *FrameReader) CopyNext(w io.Writer) (int64, error) {
+	msg, err := f.Next()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(msg)
+	return int64(n), err
+}
+
+// Skip skips the next frame without
+// reading or checking its message.
+func (f *FrameReader) Skip() error {
+	sz, err := f.header()
+	if err != nil {
+		return err
+	}
+	n := f.opts.frameSize(int(sz)) - f.opts.headerSize()
+	nn, err := f.r.R.Skip(n)
+	f.off += int64(nn)
+	return err
+}
+
+// Resync discards bytes up to the next frame
+// marker, so that reading can continue after a
+// FrameError, and returns the number of bytes
+// discarded. If it reaches the end of the stream,
+// it returns io.EOF. If the stream is already at a
+// marker, nothing is discarded; Next consumes at
+// least the marker of a frame that fails with a
+// FrameError, so calling Resync after each such
+// error always makes progress.
+//
+// The marker that Resync finds may be inside the
+// message of a frame whose header is corrupt, so
+// the frame that follows may not be genuine. With
+// checksums, such a frame almost certainly fails
+// its length checksum, and Resync can be called
+// again; without them, it may be read as a message.
+func (f *FrameReader) Resync() (int64, error) {
+	var n int64
+	for {
+		want := f.r.R.Buffered()
+		if want < len(frameMarker) {
+			want = len(frameMarker)
+		}
+		p, err := f.r.R.Peek(want)
+		if len(p) < len(frameMarker) {
+			f.r.R.Skip(len(p))
+			n += int64(len(p))
+			f.off += int64(len(p))
+			if err == nil {
+				err = io.EOF
+			}
+			return n, err
+		}
+		if i := bytes.Index(p, frameMarker); i >= 0 {
+			f.r.R.Skip(i)
+			n += int64(i)
+			f.off += int64(i)
+			return n, nil
+		}
+		// keep the last byte, which may start a marker
+		skip := len(p) - 1
+		f.r.R.Skip(skip)
+		n += int64(skip)
+		f.off += int64(skip)
+	}
+}
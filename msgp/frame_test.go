@@ -0,0 +1,198 @@
+package msgp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func writeFrames(t *testing.T, opts FrameOptions, vals ...int64) []byte {
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf, opts)
+	for _, v := range vals {
+		var n Number
+		n.AsInt(v)
+		if err := fw.Encode(&n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readInt(t *testing.T, fr *FrameReader, want int64) {
+	var n Number
+	if err := fr.Decode(&n); err != nil {
+		t.Fatalf("decoding %d: %s", want, err)
+	}
+	if v, _ := n.Int(); v != want {
+		t.Fatalf("got %d; want %d", v, want)
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	for _, opts := range []FrameOptions{
+		{},
+		{Checksum: true},
+		{Checksum: true, Magic: []byte("MSGP"), Version: 3},
+	} {
+		data := writeFrames(t, opts, 1, -2, 300000)
+		fr := NewFrameReader(bytes.NewReader(data), opts)
+		v, err := fr.Version()
+		if err != nil || v != opts.Version {
+			t.Fatalf("Version() = %d, %v", v, err)
+		}
+		readInt(t, fr, 1)
+		readInt(t, fr, -2)
+		readInt(t, fr, 300000)
+		if _, err := fr.Next(); err != io.EOF {
+			t.Errorf("expected io.EOF; got %v", err)
+		}
+		if fr.Offset() != int64(len(data)) {
+			t.Errorf("Offset() = %d; want %d", fr.Offset(), len(data))
+		}
+	}
+}
+
+func TestFrameCorrupt(t *testing.T) {
+	opts := FrameOptions{Checksum: true}
+	data := writeFrames(t, opts, 1, 2, 3)
+	size := len(data) / 3
+
+	// flip a bit in the second message
+	data[size+opts.headerSize()] ^= 0x01
+	fr := NewFrameReader(bytes.NewReader(data), opts)
+	readInt(t, fr, 1)
+	_, err := fr.Next()
+	if fe, ok := err.(FrameError); !ok || fe.Offset != int64(size) {
+		t.Fatalf("expected FrameError at %d; got %v", size, err)
+	}
+	if n, err := fr.Resync(); n != 0 || err != nil {
+		t.Fatalf("Resync() = %d, %v", n, err)
+	}
+	readInt(t, fr, 3)
+
+	// a corrupt length in the second frame
+	data = writeFrames(t, opts, 1, 2, 3)
+	data[size+2] = 0x01
+	fr = NewFrameReader(bytes.NewReader(data), opts)
+	readInt(t, fr, 1)
+	if _, err := fr.Next(); err == nil {
+		t.Fatal("expected an error")
+	} else if fe, ok := err.(FrameError); !ok || fe.Offset != int64(size) {
+		t.Fatalf("expected a FrameError at %d; got %v", size, err)
+	}
+	if n, err := fr.Resync(); n != int64(size-2) || err != nil {
+		t.Fatalf("Resync() = %d, %v", n, err)
+	}
+	readInt(t, fr, 3)
+
+	// garbage between frames
+	data = writeFrames(t, opts, 1, 2)
+	data = append(data[:size:size], append([]byte{0xc1, 0x00, 0x01, 0xc1}, data[size:]...)...)
+	fr = NewFrameReader(bytes.NewReader(data), opts)
+	readInt(t, fr, 1)
+	if _, err := fr.Next(); err == nil {
+		t.Fatal("expected an error")
+	} else if _, ok := err.(FrameError); !ok {
+		t.Fatalf("expected a FrameError; got %v", err)
+	}
+	if n, err := fr.Resync(); n != 4 || err != nil {
+		t.Fatalf("Resync() = %d, %v", n, err)
+	}
+	readInt(t, fr, 2)
+	if n, err := fr.Resync(); n != 0 || err != io.EOF {
+		t.Fatalf("Resync() at the end = %d, %v", n, err)
+	}
+}
+
+func TestFrameTruncated(t *testing.T) {
+	opts := FrameOptions{Checksum: true}
+	data := writeFrames(t, opts, 1, 2)
+	size := len(data) / 2
+	cases := []struct {
+		n   int
+		off int // Offset() after the error
+	}{
+		// a short header isn't consumed
+		{size + 1, size},
+		{size + frameHeaderSize, size},
+		// a short message is
+		{len(data) - 1, len(data) - 1},
+	}
+	for _, c := range cases {
+		fr := NewFrameReader(bytes.NewReader(data[:c.n]), opts)
+		readInt(t, fr, 1)
+		if _, err := fr.Next(); err != io.ErrUnexpectedEOF {
+			t.Errorf("%d bytes: expected io.ErrUnexpectedEOF; got %v", c.n, err)
+		}
+		if fr.Offset() != int64(c.off) {
+			t.Errorf("%d bytes: Offset() = %d; want %d", c.n, fr.Offset(), c.off)
+		}
+	}
+}
+
+func TestFrameOptions(t *testing.T) {
+	data := writeFrames(t, FrameOptions{Magic: []byte("AB")}, 1)
+	fr := NewFrameReader(bytes.NewReader(data), FrameOptions{Magic: []byte("XY")})
+	if _, err := fr.Next(); err == nil {
+		t.Error("expected an error for bad magic")
+	}
+	fr = NewFrameReader(bytes.NewReader(nil), FrameOptions{Magic: []byte("AB")})
+	if _, err := fr.Next(); err != io.EOF {
+		t.Errorf("empty stream: expected io.EOF; got %v", err)
+	}
+
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf, FrameOptions{})
+	if err := fw.WriteFrame(AppendString(nil, "a long string")); err != nil {
+		t.Fatal(err)
+	}
+	fw.Flush()
+	fr = NewFrameReader(&buf, FrameOptions{MaxSize: 4})
+	if _, err := fr.Next(); err == nil {
+		t.Error("expected an error for a large frame")
+	} else if _, ok := err.(FrameError); !ok {
+		t.Errorf("expected a FrameError; got %v", err)
+	}
+}
+
+func TestFrameCopySkip(t *testing.T) {
+	var src bytes.Buffer
+	w := NewWriter(&src)
+	w.WriteMapHeader(1)
+	w.WriteString("a")
+	w.WriteArrayHeader(2)
+	w.WriteInt(1)
+	w.WriteBool(true)
+	w.WriteString("next")
+	w.Flush()
+	msg := append([]byte(nil), src.Bytes()...)
+
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf, FrameOptions{Checksum: true})
+	r := NewReader(&src)
+	for i := 0; i < 2; i++ {
+		if err := fw.CopyNext(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fw.Flush()
+
+	fr := NewFrameReader(bytes.NewReader(buf.Bytes()), FrameOptions{Checksum: true})
+	var out bytes.Buffer
+	if _, err := fr.CopyNext(&out); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fr.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF; got %v", err)
+	}
+	if first := msg[:len(msg)-5]; !bytes.Equal(out.Bytes(), first) {
+		t.Errorf("CopyNext wrote %x; want %x", out.Bytes(), first)
+	}
+}
@@ -0,0 +1,235 @@
+// Package httpmsgp has helpers for HTTP handlers
+// that read and write MessagePack, with JSON for
+// clients that ask for it.
+//
+// Request bodies are decoded according to their
+// Content-Type, and responses are written as MessagePack
+// or JSON according to the request's Accept header.
+// JSON responses are translated from the MessagePack
+// encoding with msgp.CopyToJSON, so types only need
+// generated methods.
+//
+// JSON responses use the field names from `msg` tags,
+// because they are translated from MessagePack. JSON
+// can't be translated back without knowing the types of
+// the fields, so JSON request bodies are only accepted for
+// types that implement json.Unmarshaler, which are responsible
+// for accepting the same names; otherwise, Decode rejects
+// them, rather than silently decoding them with different
+// field names.
+package httpmsgp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	// ContentType is the media type of MessagePack.
+	ContentType = "application/msgpack"
+
+	// JSONContentType is the media type of JSON.
+	JSONContentType = "application/json"
+)
+
+// msgpackTypes are the media types accepted as MessagePack
+var msgpackTypes = []string{ContentType, "application/x-msgpack"}
+
+// Codec holds the options for decoding
+// requests and writing responses.
+type Codec struct {
+	// MaxBytes is the largest request body that is
+	// read. If it is zero or negative, there is no limit.
+	MaxBytes int64
+
+	// JSON renders JSON responses. If it is nil,
+	// they are rendered like msgp.CopyToJSON.
+	JSON *msgp.JSONOptions
+}
+
+// Default is the Codec used by Decode and Encode.
+// Its MaxBytes is 1MB.
+var Default = &Codec{MaxBytes: 1 << 20}
+
+// Error is an error decoding a request, with the
+// HTTP status code that should be sent for it.
+type Error struct {
+	Status int
+	Err    error
+}
+
+// Error implements the error interface
+func (e *Error) Error() string { return "httpmsgp: " + e.Err.Error() }
+
+// errTooLarge is returned by limitReader
+var errTooLarge = errors.New("request body too large")
+
+// limitReader returns errTooLarge once
+// more than 'n' bytes have been read
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errTooLarge
+	}
+	return n, err
+}
+
+// Decode decodes the body of 'r' using the Default Codec.
+func Decode(r *http.Request, d msgp.Decodable) error {
+	return Default.Decode(r, d)
+}
+
+// Decode decodes the body of 'r' into 'd'. MessagePack
+// bodies are decoded with d.DecodeMsg, and JSON bodies
+// with d.UnmarshalJSON, if 'd' implements json.Unmarshaler.
+// (See the package documentation.) Errors are of type *Error,
+// with a status of 415 for any other Content-Type, or for
+// JSON if 'd' can't decode it, 413 if the body is larger
+// than c.MaxBytes, and 400 otherwise.
+func (c *Codec) Decode(r *http.Request, d msgp.Decodable) error {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return &Error{Status: http.StatusUnsupportedMediaType, Err: err}
+	}
+	var body io.Reader = r.Body
+	if c.MaxBytes > 0 {
+		body = &limitReader{r: r.Body, n: c.MaxBytes}
+	}
+	switch {
+	case isMsgpack(mt):
+		err = msgp.Decode(body, d)
+	case mt == JSONContentType:
+		u, ok := d.(json.Unmarshaler)
+		if !ok {
+			return &Error{
+				Status: http.StatusUnsupportedMediaType,
+				Err:    fmt.Errorf("%T can't be decoded from JSON", d),
+			}
+		}
+		err = json.NewDecoder(body).Decode(u)
+	default:
+		return &Error{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    fmt.Errorf("unsupported content type %q", mt),
+		}
+	}
+	switch {
+	case err == nil:
+		return nil
+	case err == errTooLarge:
+		return &Error{Status: http.StatusRequestEntityTooLarge, Err: errTooLarge}
+	default:
+		return &Error{Status: http.StatusBadRequest, Err: err}
+	}
+}
+
+// Encode writes a response using the Default Codec.
+func Encode(w http.ResponseWriter, r *http.Request, status int, e msgp.Encodable) error {
+	return Default.Encode(w, r, status, e)
+}
+
+// Encode writes 'e' as the response to 'r' with the status
+// code 'status', as MessagePack or JSON according to the
+// Accept header of 'r' (see Negotiate). 'e' is encoded before
+// anything is written, so if it can't be encoded, Encode
+// returns the error, and the handler can still send an error
+// response.
+func (c *Codec) Encode(w http.ResponseWriter, r *http.Request, status int, e msgp.Encodable) error {
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, e); err != nil {
+		return err
+	}
+	h := w.Header()
+	h.Add("Vary", "Accept")
+	if Negotiate(r) == ContentType {
+		h.Set("Content-Type", ContentType)
+		h.Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.WriteHeader(status)
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+	h.Set("Content-Type", JSONContentType)
+	w.WriteHeader(status)
+	var err error
+	if c.JSON != nil {
+		_, err = c.JSON.CopyToJSON(w, &buf)
+	} else {
+		_, err = msgp.CopyToJSON(w, &buf)
+	}
+	return err
+}
+
+// Negotiate returns ContentType if the Accept header of 'r'
+// prefers MessagePack to JSON, and JSONContentType otherwise,
+// including when neither is acceptable. Quality values are
+// respected, and wildcards count for both types.
+func Negotiate(r *http.Request) string {
+	var mq, jq, wq float64 = -1, -1, -1
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case isMsgpack(mt):
+			if q > mq {
+				mq = q
+			}
+		case mt == JSONContentType:
+			if q > jq {
+				jq = q
+			}
+		case mt == "*/*" || mt == "application/*":
+			if q > wq {
+				wq = q
+			}
+		}
+	}
+	// specific types take precedence over wildcards
+	if mq < 0 {
+		mq = wq
+	}
+	if jq < 0 {
+		jq = wq
+	}
+	if mq > 0 && mq > jq {
+		return ContentType
+	}
+	return JSONContentType
+}
+
+func isMsgpack(mt string) bool {
+	for _, t := range msgpackTypes {
+		if mt == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package httpmsgp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// user is encoded as {"name": <string>}
+type user struct {
+	Name string `json:"name"`
+}
+
+// UnmarshalJSON accepts the JSON written by Encode
+func (u *user) UnmarshalJSON(b []byte) error {
+	type plain user
+	return json.Unmarshal(b, (*plain)(u))
+}
+
+// msgOnly is a user that can't be decoded from JSON
+type msgOnly struct{ u user }
+
+func (m *msgOnly) EncodeMsg(w *msgp.Writer) error { return m.u.EncodeMsg(w) }
+func (m *msgOnly) DecodeMsg(r *msgp.Reader) error { return m.u.DecodeMsg(r) }
+
+func (u *user) EncodeMsg(w *msgp.Writer) error {
+	if err := w.WriteMapHeader(1); err != nil {
+		return err
+	}
+	if err := w.WriteString("name"); err != nil {
+		return err
+	}
+	return w.WriteString(u.Name)
+}
+
+func (u *user) DecodeMsg(r *msgp.Reader) error {
+	sz, err := r.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < sz; i++ {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		if key != "name" {
+			if err := r.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if u.Name, err = r.ReadString(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", JSONContentType},
+		{"*/*", JSONContentType},
+		{"application/msgpack", ContentType},
+		{"application/x-msgpack", ContentType},
+		{"application/json, application/msgpack", JSONContentType},
+		{"application/json;q=0.5, application/msgpack", ContentType},
+		{"application/msgpack;q=0.5, */*", JSONContentType},
+		{"application/msgpack;q=0.5, application/*;q=0.1", ContentType},
+		{"application/msgpack;q=0", JSONContentType},
+		{"text/html", JSONContentType},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tc.accept)
+		if got := Negotiate(r); got != tc.want {
+			t.Errorf("Negotiate(%q) = %s; want %s", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	msgp.Encode(&buf, &user{Name: "ann"})
+	cases := []struct {
+		ctype  string
+		body   string
+		max    int64
+		status int
+	}{
+		{"application/msgpack", buf.String(), 0, 0},
+		{"application/x-msgpack", buf.String(), int64(buf.Len()), 0},
+		{"application/json; charset=utf-8", `{"name":"ann"}`, 100, 0},
+		{"application/msgpack", buf.String(), int64(buf.Len() - 1), http.StatusRequestEntityTooLarge},
+		{"application/json", `{"name":"ann"}`, 5, http.StatusRequestEntityTooLarge},
+		{"application/json", `{"name":`, 100, http.StatusBadRequest},
+		{"application/msgpack", "\xc1", 100, http.StatusBadRequest},
+		{"text/plain", "ann", 100, http.StatusUnsupportedMediaType},
+		{"", "ann", 100, http.StatusUnsupportedMediaType},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+		r.Header.Set("Content-Type", tc.ctype)
+		c := Codec{MaxBytes: tc.max}
+		var u user
+		err := c.Decode(r, &u)
+		if tc.status == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", tc.ctype, err)
+			} else if u.Name != "ann" {
+				t.Errorf("%s: decoded %+v", tc.ctype, u)
+			}
+			continue
+		}
+		if e, ok := err.(*Error); !ok || e.Status != tc.status {
+			t.Errorf("%s %q: got error %v; want status %d", tc.ctype, tc.body, err, tc.status)
+		}
+	}
+}
+
+func TestDecodeJSONUnsupported(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"ann"}`))
+	r.Header.Set("Content-Type", "application/json")
+	var m msgOnly
+	err := Decode(r, &m)
+	if e, ok := err.(*Error); !ok || e.Status != http.StatusUnsupportedMediaType {
+		t.Errorf("got error %v; want status %d", err, http.StatusUnsupportedMediaType)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	if err := Encode(w, r, http.StatusOK, &user{Name: "ann"}); err != nil {
+		t.Fatal(err)
+	}
+	r = httptest.NewRequest("POST", "/", w.Body)
+	r.Header.Set("Content-Type", w.Header().Get("Content-Type"))
+	var u user
+	if err := Decode(r, &u); err != nil || u.Name != "ann" {
+		t.Errorf("decoded %+v, %v", u, err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/msgpack")
+	w := httptest.NewRecorder()
+	if err := Encode(w, r, http.StatusCreated, &user{Name: "ann"}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != ContentType {
+		t.Errorf("got status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var u user
+	if err := msgp.Decode(w.Body, &u); err != nil || u.Name != "ann" {
+		t.Errorf("decoded %+v, %v", u, err)
+	}
+
+	r.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	c := Codec{JSON: &msgp.JSONOptions{Indent: " "}}
+	if err := c.Encode(w, r, http.StatusOK, &user{Name: "ann"}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Content-Type") != JSONContentType {
+		t.Errorf("got content type %q", w.Header().Get("Content-Type"))
+	}
+	if got := w.Body.String(); got != "{\n \"name\": \"ann\"\n}" {
+		t.Errorf("got body %q", got)
+	}
+	if w.Header().Get("Vary") != "Accept" {
+		t.Errorf("got Vary %q", w.Header().Get("Vary"))
+	}
+}
@@ -0,0 +1,71 @@
+package msgp
+
+import (
+	"net"
+)
+
+// A net.IP is encoded as a MessagePack 'bin'
+// object holding the 4- or 16-byte address.
+// A nil net.IP is encoded as an empty 'bin'.
+
+// WriteIP writes a net.IP to the writer.
+// IPv4 addresses are written in their
+// 4-byte form.
+func (mw *Writer) WriteIP(ip net.IP) error {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return mw.WriteBytes(ip)
+}
+
+// ReadIP reads a net.IP from the reader.
+// An empty 'bin' is returned as a nil net.IP.
+func (m *Reader) ReadIP() (ip net.IP, err error) {
+	ip, err = m.ReadBytes(nil)
+	if err != nil {
+		return
+	}
+	return checkIP(ip)
+}
+
+// AppendIP appends a net.IP to the slice.
+// IPv4 addresses are written in their
+// 4-byte form.
+func AppendIP(b []byte, ip net.IP) []byte {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return AppendBytes(b, ip)
+}
+
+// ReadIPBytes reads a net.IP from 'b'
+// and returns the remaining bytes. The
+// returned net.IP does not alias 'b'.
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not 'bin')
+// - ArrayError{} (object not 0, 4 or 16 bytes long)
+func ReadIPBytes(b []byte) (ip net.IP, o []byte, err error) {
+	var v []byte
+	v, o, err = ReadBytesZC(b)
+	if err != nil {
+		return
+	}
+	if len(v) > 0 {
+		ip = make(net.IP, len(v))
+		copy(ip, v)
+	}
+	ip, err = checkIP(ip)
+	return
+}
+
+func checkIP(ip net.IP) (net.IP, error) {
+	switch len(ip) {
+	case 0:
+		return nil, nil
+	case net.IPv4len, net.IPv6len:
+		return ip, nil
+	default:
+		return nil, ArrayError{Wanted: net.IPv6len, Got: uint32(len(ip))}
+	}
+}
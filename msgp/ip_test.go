@@ -0,0 +1,46 @@
+package msgp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestReadWriteIP(t *testing.T) {
+	ips := []net.IP{nil, net.ParseIP("127.0.0.1"), net.ParseIP("2001:db8::68")}
+	var buf bytes.Buffer
+	en := NewWriter(&buf)
+	dc := NewReader(&buf)
+	for _, in := range ips {
+		buf.Reset()
+		if err := en.WriteIP(in); err != nil {
+			t.Fatal(err)
+		}
+		en.Flush()
+		if buf.Len() > IPSize {
+			t.Errorf("%s: encoded %d bytes", in, buf.Len())
+		}
+		b := AppendIP(nil, in)
+		if !bytes.Equal(b, buf.Bytes()) {
+			t.Errorf("%s: AppendIP and WriteIP disagree", in)
+		}
+		out, err := dc.ReadIP()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !out.Equal(in) {
+			t.Errorf("got %s; want %s", out, in)
+		}
+		out, b, err = ReadIPBytes(b)
+		if err != nil || len(b) != 0 {
+			t.Fatal(err, len(b))
+		}
+		if !out.Equal(in) {
+			t.Errorf("got %s; want %s", out, in)
+		}
+	}
+
+	if _, _, err := ReadIPBytes(AppendBytes(nil, []byte{1, 2, 3})); err == nil {
+		t.Error("expected an error for a 3-byte address")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"io"
+	"math"
 	"strconv"
+	"time"
 	"unicode/utf8"
 )
 
@@ -14,7 +16,7 @@ var (
 	hex  = []byte("0123456789abcdef")
 )
 
-var defuns [_maxtype]func(jsWriter, *Reader) (int, error)
+var defuns [_maxtype]func(jsWriter, *Reader, *jsonState) (int, error)
 
 // note: there is an initialization loop if
 // this isn't set up during init()
@@ -22,7 +24,7 @@ func init() {
 	// since none of these functions are inline-able,
 	// there is not much of a penalty to the indirect
 	// call. however, this is best expressed as a jump-table...
-	defuns = [_maxtype]func(jsWriter, *Reader) (int, error){
+	defuns = [_maxtype]func(jsWriter, *Reader, *jsonState) (int, error){
 		StrType:        rwString,
 		BinType:        rwBytes,
 		MapType:        rwMap,
@@ -48,11 +50,304 @@ type jsWriter interface {
 	WriteString(string) (int, error)
 }
 
+// BinFormat is the JSON rendering of bin objects.
+type BinFormat uint8
+
+const (
+	BinBase64 BinFormat = iota // a base64 string (the default)
+	BinHex                     // a lowercase hex string
+	BinArray                   // an array of numbers
+)
+
+// NonFinite is the JSON rendering of NaN
+// and infinite floats, which JSON can't represent.
+type NonFinite uint8
+
+const (
+	NonFiniteLiteral NonFinite = iota // write NaN, +Inf or -Inf, which isn't valid JSON (the default)
+	NonFiniteReject                   // return a NonFiniteError
+	NonFiniteNull                     // write null
+	NonFiniteString                   // write "NaN", "+Inf" or "-Inf"
+)
+
+// JSONOptions control how MessagePack is rendered as
+// JSON by (*JSONOptions).CopyToJSON and (*JSONOptions).UnmarshalAsJSON.
+// The zero value renders JSON the same way as
+// CopyToJSON and UnmarshalAsJSON.
+type JSONOptions struct {
+	// Prefix and Indent, if either is set, indent
+	// the output in the same way as json.MarshalIndent.
+	Prefix string
+	Indent string
+
+	// Bin is the rendering of bin objects,
+	// and of the data of unknown extensions.
+	Bin BinFormat
+
+	// TimeFormat is the layout used to write times,
+	// as in time.Format. The default is time.RFC3339Nano.
+	TimeFormat string
+
+	// NonFinite is the rendering of NaN and infinite floats.
+	NonFinite NonFinite
+
+	// StringifyKeys writes map keys that are integers,
+	// floats, bools or nil as strings. Otherwise, map keys
+	// that aren't str or bin are an error.
+	StringifyKeys bool
+
+	// QuoteLargeInts writes integers outside of the range
+	// that a float64 can represent exactly (+/- 2^53-1)
+	// as strings, so that JavaScript clients can read them.
+	QuoteLargeInts bool
+
+	// Extensions is used to look up extension types,
+	// which are written using their MarshalJSON method,
+	// if they have one. If it is nil, DefaultExtensions
+	// is used, or the Reader's Extensions, if set.
+	Extensions *ExtensionRegistry
+
+	// Extension, if non-nil, is called with each extension
+	// other than time.Time before Extensions is consulted.
+	// It returns the extension as JSON, or nil to fall back
+	// to the default rendering. Extensions that aren't
+	// handled by either are written as
+	// {"type":<number>,"data":<bin>}.
+	Extension func(typ int8, data []byte) ([]byte, error)
+}
+
+// defaultJSON renders CopyToJSON and UnmarshalAsJSON
+var defaultJSON JSONOptions
+
+// jsonState is the state of a single translation to JSON
+type jsonState struct {
+	opts    *JSONOptions
+	ext     *ExtensionRegistry
+	indent  bool
+	depth   int
+	scratch []byte
+}
+
+// state returns a new translation using 'o',
+// with 'ext' as the default extension registry
+func (o *JSONOptions) state(ext *ExtensionRegistry) *jsonState {
+	if o.Extensions != nil {
+		ext = o.Extensions
+	}
+	return &jsonState{
+		opts:   o,
+		ext:    ext,
+		indent: o.Prefix != "" || o.Indent != "",
+	}
+}
+
+// write writes 'b', which is kept as scratch space
+func (s *jsonState) write(w jsWriter, b []byte) (int, error) {
+	s.scratch = b
+	return w.Write(b)
+}
+
+// appendNewline appends a newline and the
+// indentation for the current depth, if the
+// output is indented
+func (s *jsonState) appendNewline(b []byte) []byte {
+	if !s.indent {
+		return b
+	}
+	b = append(b, '\n')
+	b = append(b, s.opts.Prefix...)
+	for i := 0; i < s.depth; i++ {
+		b = append(b, s.opts.Indent...)
+	}
+	return b
+}
+
+// appendColon appends the separator
+// between an object key and its value
+func (s *jsonState) appendColon(b []byte) []byte {
+	if s.indent {
+		return append(b, ':', ' ')
+	}
+	return append(b, ':')
+}
+
+// newline writes the output of appendNewline
+func (s *jsonState) newline(w jsWriter) (int, error) {
+	if !s.indent {
+		return 0, nil
+	}
+	return s.write(w, s.appendNewline(s.scratch[:0]))
+}
+
+// colon writes the output of appendColon
+func (s *jsonState) colon(w jsWriter) (int, error) {
+	if !s.indent {
+		return 1, w.WriteByte(':')
+	}
+	return w.WriteString(": ")
+}
+
+// appendBin appends 'data' in the Bin format
+func (s *jsonState) appendBin(b []byte, data []byte) []byte {
+	switch s.opts.Bin {
+	case BinHex:
+		b = append(b, '"')
+		for _, c := range data {
+			b = append(b, hex[c>>4], hex[c&0xf])
+		}
+		return append(b, '"')
+	case BinArray:
+		b = append(b, '[')
+		for i, c := range data {
+			if i != 0 {
+				b = append(b, ',')
+			}
+			b = strconv.AppendUint(b, uint64(c), 10)
+		}
+		return append(b, ']')
+	default:
+		b = append(b, '"')
+		o := len(b)
+		b = append(b, make([]byte, base64.StdEncoding.EncodedLen(len(data)))...)
+		base64.StdEncoding.Encode(b[o:], data)
+		return append(b, '"')
+	}
+}
+
+// writeFloat writes a float of size 'bits'
+func (s *jsonState) writeFloat(w jsWriter, f float64, bits int) (int, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		switch s.opts.NonFinite {
+		case NonFiniteNull:
+			return w.Write(null)
+		case NonFiniteString:
+			s.scratch = strconv.AppendFloat(s.scratch[:0], f, 'g', -1, bits)
+			return rwquoted(w, s.scratch)
+		case NonFiniteReject:
+			return 0, NonFiniteError{Value: f}
+		}
+	}
+	return s.write(w, strconv.AppendFloat(s.scratch[:0], f, 'f', -1, bits))
+}
+
+// maxSafeInt is the largest integer that
+// a float64 (or a JavaScript number) can
+// represent exactly
+const maxSafeInt = 1<<53 - 1
+
+func (s *jsonState) writeInt(w jsWriter, i int64) (int, error) {
+	s.scratch = strconv.AppendInt(s.scratch[:0], i, 10)
+	if s.opts.QuoteLargeInts && (i > maxSafeInt || i < -maxSafeInt) {
+		return rwquoted(w, s.scratch)
+	}
+	return w.Write(s.scratch)
+}
+
+func (s *jsonState) writeUint(w jsWriter, u uint64) (int, error) {
+	s.scratch = strconv.AppendUint(s.scratch[:0], u, 10)
+	if s.opts.QuoteLargeInts && u > maxSafeInt {
+		return rwquoted(w, s.scratch)
+	}
+	return w.Write(s.scratch)
+}
+
+func (s *jsonState) writeTime(w jsWriter, t time.Time) (int, error) {
+	if s.opts.TimeFormat == "" {
+		bts, err := t.MarshalJSON()
+		if err != nil {
+			return 0, err
+		}
+		return w.Write(bts)
+	}
+	s.scratch = t.AppendFormat(s.scratch[:0], s.opts.TimeFormat)
+	return rwquoted(w, s.scratch)
+}
+
+// writeExt writes any extension other than time.Time
+func (s *jsonState) writeExt(w jsWriter, r *RawExtension) (int, error) {
+	if s.opts.Extension != nil {
+		bts, err := s.opts.Extension(r.Type, r.Data)
+		if err != nil {
+			return 0, err
+		}
+		if bts != nil {
+			return w.Write(bts)
+		}
+	}
+
+	// if the extension is registered,
+	// use its canonical JSON form
+	if f, ok := s.ext.Lookup(r.Type); ok {
+		x := f()
+		if err := x.UnmarshalBinary(r.Data); err != nil {
+			return 0, err
+		}
+		bts, err := json.Marshal(x)
+		if err != nil {
+			return 0, err
+		}
+		return w.Write(bts)
+	}
+
+	// otherwise, write {"type": <num>, "data": <bin>}
+	b := append(s.scratch[:0], '{')
+	s.depth++
+	b = s.appendNewline(b)
+	b = append(b, `"type"`...)
+	b = s.appendColon(b)
+	b = strconv.AppendInt(b, int64(r.Type), 10)
+	b = append(b, ',')
+	b = s.appendNewline(b)
+	b = append(b, `"data"`...)
+	b = s.appendColon(b)
+	b = s.appendBin(b, r.Data)
+	s.depth--
+	b = s.appendNewline(b)
+	b = append(b, '}')
+	return s.write(w, b)
+}
+
+// appendKey appends the scalar 'v'
+// as the text of an object key
+func appendKey(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case int64:
+		return strconv.AppendInt(b, v, 10)
+	case uint64:
+		return strconv.AppendUint(b, v, 10)
+	case float32:
+		return strconv.AppendFloat(b, float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.AppendFloat(b, v, 'g', -1, 64)
+	case bool:
+		return strconv.AppendBool(b, v)
+	default:
+		return append(b, null...)
+	}
+}
+
+// isScalarKey returns whether objects of type
+// 't' can be written as keys with StringifyKeys
+func isScalarKey(t Type) bool {
+	switch t {
+	case IntType, UintType, Float32Type, Float64Type, BoolType, NilType:
+		return true
+	default:
+		return false
+	}
+}
+
 // CopyToJSON reads MessagePack from 'src' and copies it
 // as JSON to 'dst' until EOF.
 func CopyToJSON(dst io.Writer, src io.Reader) (n int64, err error) {
+	return defaultJSON.CopyToJSON(dst, src)
+}
+
+// CopyToJSON is like the package-level CopyToJSON,
+// but it renders JSON according to 'o'.
+func (o *JSONOptions) CopyToJSON(dst io.Writer, src io.Reader) (n int64, err error) {
 	r := NewReader(src)
-	n, err = r.WriteToJSON(dst)
+	n, err = r.writeToJSON(dst, o)
 	freeR(r)
 	return
 }
@@ -61,6 +356,10 @@ func CopyToJSON(dst io.Writer, src io.Reader) (n int64, err error) {
 // JSON to 'w' until the underlying reader returns io.EOF. It returns
 // the number of bytes written, and an error if it stopped before EOF.
 func (r *Reader) WriteToJSON(w io.Writer) (n int64, err error) {
+	return r.writeToJSON(w, &defaultJSON)
+}
+
+func (r *Reader) writeToJSON(w io.Writer, o *JSONOptions) (n int64, err error) {
 	var j jsWriter
 	var bf *bufio.Writer
 	if jsw, ok := w.(jsWriter); ok {
@@ -69,9 +368,10 @@ func (r *Reader) WriteToJSON(w io.Writer) (n int64, err error) {
 		bf = bufio.NewWriter(w)
 		j = bf
 	}
+	s := o.state(r.extensions())
 	var nn int
 	for err == nil {
-		nn, err = rwNext(j, r)
+		nn, err = rwNext(j, r, s)
 		n += int64(nn)
 	}
 	if err != io.EOF {
@@ -87,18 +387,17 @@ func (r *Reader) WriteToJSON(w io.Writer) (n int64, err error) {
 	return
 }
 
-func rwNext(w jsWriter, src *Reader) (int, error) {
+func rwNext(w jsWriter, src *Reader, s *jsonState) (int, error) {
 	t, err := src.NextType()
 	if err != nil {
 		return 0, err
 	}
-	return defuns[t](w, src)
+	return defuns[t](w, src, s)
 }
 
-func rwMap(dst jsWriter, src *Reader) (n int, err error) {
+func rwMap(dst jsWriter, src *Reader, s *jsonState) (n int, err error) {
 	var comma bool
 	var sz uint32
-	var field []byte
 
 	sz, err = src.ReadMapHeader()
 	if err != nil {
@@ -114,6 +413,7 @@ func rwMap(dst jsWriter, src *Reader) (n int, err error) {
 		return
 	}
 	n++
+	s.depth++
 	var nn int
 	for i := uint32(0); i < sz; i++ {
 		if comma {
@@ -123,23 +423,24 @@ func rwMap(dst jsWriter, src *Reader) (n int, err error) {
 			}
 			n++
 		}
-
-		field, err = src.ReadMapKeyPtr()
+		nn, err = s.newline(dst)
+		n += nn
 		if err != nil {
 			return
 		}
-		nn, err = rwquoted(dst, field)
+
+		nn, err = rwMapKey(dst, src, s)
 		n += nn
 		if err != nil {
 			return
 		}
 
-		err = dst.WriteByte(':')
+		nn, err = s.colon(dst)
+		n += nn
 		if err != nil {
 			return
 		}
-		n++
-		nn, err = rwNext(dst, src)
+		nn, err = rwNext(dst, src, s)
 		n += nn
 		if err != nil {
 			return
@@ -148,6 +449,12 @@ func rwMap(dst jsWriter, src *Reader) (n int, err error) {
 			comma = true
 		}
 	}
+	s.depth--
+	nn, err = s.newline(dst)
+	n += nn
+	if err != nil {
+		return
+	}
 
 	err = dst.WriteByte('}')
 	if err != nil {
@@ -157,17 +464,42 @@ func rwMap(dst jsWriter, src *Reader) (n int, err error) {
 	return
 }
 
-func rwArray(dst jsWriter, src *Reader) (n int, err error) {
-	err = dst.WriteByte('[')
+func rwMapKey(dst jsWriter, src *Reader, s *jsonState) (int, error) {
+	t, err := src.NextType()
 	if err != nil {
-		return
+		return 0, err
+	}
+	if s.opts.StringifyKeys && isScalarKey(t) {
+		v, err := src.ReadIntf()
+		if err != nil {
+			return 0, err
+		}
+		s.scratch = appendKey(s.scratch[:0], v)
+		return rwquoted(dst, s.scratch)
+	}
+	field, err := src.ReadMapKeyPtr()
+	if err != nil {
+		return 0, err
 	}
+	return rwquoted(dst, field)
+}
+
+func rwArray(dst jsWriter, src *Reader, s *jsonState) (n int, err error) {
 	var sz uint32
 	var nn int
 	sz, err = src.ReadArrayHeader()
 	if err != nil {
 		return
 	}
+	if sz == 0 {
+		return dst.WriteString("[]")
+	}
+	err = dst.WriteByte('[')
+	if err != nil {
+		return
+	}
+	n++
+	s.depth++
 	comma := false
 	for i := uint32(0); i < sz; i++ {
 		if comma {
@@ -177,13 +509,24 @@ func rwArray(dst jsWriter, src *Reader) (n int, err error) {
 			}
 			n++
 		}
-		nn, err = rwNext(dst, src)
+		nn, err = s.newline(dst)
+		n += nn
+		if err != nil {
+			return
+		}
+		nn, err = rwNext(dst, src, s)
 		n += nn
 		if err != nil {
 			return
 		}
 		comma = true
 	}
+	s.depth--
+	nn, err = s.newline(dst)
+	n += nn
+	if err != nil {
+		return
+	}
 
 	err = dst.WriteByte(']')
 	if err != nil {
@@ -193,7 +536,7 @@ func rwArray(dst jsWriter, src *Reader) (n int, err error) {
 	return
 }
 
-func rwNil(dst jsWriter, src *Reader) (int, error) {
+func rwNil(dst jsWriter, src *Reader, s *jsonState) (int, error) {
 	err := src.ReadNil()
 	if err != nil {
 		return 0, err
@@ -201,43 +544,39 @@ func rwNil(dst jsWriter, src *Reader) (int, error) {
 	return dst.Write(null)
 }
 
-func rwFloat32(dst jsWriter, src *Reader) (int, error) {
+func rwFloat32(dst jsWriter, src *Reader, s *jsonState) (int, error) {
 	f, err := src.ReadFloat32()
 	if err != nil {
 		return 0, err
 	}
-	src.scratch = strconv.AppendFloat(src.scratch[:0], float64(f), 'f', -1, 64)
-	return dst.Write(src.scratch)
+	return s.writeFloat(dst, float64(f), 32)
 }
 
-func rwFloat64(dst jsWriter, src *Reader) (int, error) {
+func rwFloat64(dst jsWriter, src *Reader, s *jsonState) (int, error) {
 	f, err := src.ReadFloat64()
 	if err != nil {
 		return 0, err
 	}
-	src.scratch = strconv.AppendFloat(src.scratch[:0], f, 'f', -1, 32)
-	return dst.Write(src.scratch)
+	return s.writeFloat(dst, f, 64)
 }
 
-func rwInt(dst jsWriter, src *Reader) (int, error) {
+func rwInt(dst jsWriter, src *Reader, s *jsonState) (int, error) {
 	i, err := src.ReadInt64()
 	if err != nil {
 		return 0, err
 	}
-	src.scratch = strconv.AppendInt(src.scratch[:0], i, 10)
-	return dst.Write(src.scratch)
+	return s.writeInt(dst, i)
 }
 
-func rwUint(dst jsWriter, src *Reader) (int, error) {
+func rwUint(dst jsWriter, src *Reader, s *jsonState) (int, error) {
 	u, err := src.ReadUint64()
 	if err != nil {
 		return 0, err
 	}
-	src.scratch = strconv.AppendUint(src.scratch[:0], u, 10)
-	return dst.Write(src.scratch)
+	return s.writeUint(dst, u)
 }
 
-func rwBool(dst jsWriter, src *Reader) (int, error) {
+func rwBool(dst jsWriter, src *Reader, s *jsonState) (int, error) {
 	b, err := src.ReadBool()
 	if err != nil {
 		return 0, err
@@ -248,90 +587,29 @@ func rwBool(dst jsWriter, src *Reader) (int, error) {
 	return dst.WriteString("false")
 }
 
-func rwTime(dst jsWriter, src *Reader) (int, error) {
+func rwTime(dst jsWriter, src *Reader, s *jsonState) (int, error) {
 	t, err := src.ReadTime()
 	if err != nil {
 		return 0, err
 	}
-	bts, err := t.MarshalJSON()
-	if err != nil {
-		return 0, err
-	}
-	return dst.Write(bts)
+	return s.writeTime(dst, t)
 }
 
-func rwExtension(dst jsWriter, src *Reader) (n int, err error) {
+func rwExtension(dst jsWriter, src *Reader, s *jsonState) (int, error) {
 	et, err := src.peekExtensionType()
 	if err != nil {
 		return 0, err
 	}
-
-	// registered extensions can override
-	// the JSON encoding
-	if j, ok := extensionReg[et]; ok {
-		var bts []byte
-		e := j()
-		err = src.ReadExtension(e)
-		if err != nil {
-			return
-		}
-		bts, err = json.Marshal(e)
-		if err != nil {
-			return
-		}
-		return dst.Write(bts)
-	}
-
 	e := RawExtension{}
 	e.Type = et
 	err = src.ReadExtension(&e)
 	if err != nil {
-		return
-	}
-
-	var nn int
-	err = dst.WriteByte('{')
-	if err != nil {
-		return
-	}
-	n++
-
-	nn, err = dst.WriteString(`"type:"`)
-	n += nn
-	if err != nil {
-		return
-	}
-
-	src.scratch = strconv.AppendInt(src.scratch[0:0], int64(e.Type), 10)
-	nn, err = dst.Write(src.scratch)
-	n += nn
-	if err != nil {
-		return
-	}
-
-	nn, err = dst.WriteString(`,"data":"`)
-	n += nn
-	if err != nil {
-		return
-	}
-
-	enc := base64.NewEncoder(base64.StdEncoding, dst)
-
-	nn, err = enc.Write(e.Data)
-	n += nn
-	if err != nil {
-		return
-	}
-	err = enc.Close()
-	if err != nil {
-		return
+		return 0, err
 	}
-	nn, err = dst.WriteString(`"}`)
-	n += nn
-	return
+	return s.writeExt(dst, &e)
 }
 
-func rwString(dst jsWriter, src *Reader) (n int, err error) {
+func rwString(dst jsWriter, src *Reader, s *jsonState) (n int, err error) {
 	var p []byte
 	p, err = src.R.Peek(1)
 	if err != nil {
@@ -378,33 +656,13 @@ write:
 	return
 }
 
-func rwBytes(dst jsWriter, src *Reader) (n int, err error) {
-	var nn int
-	err = dst.WriteByte('"')
-	if err != nil {
-		return
-	}
-	n++
+func rwBytes(dst jsWriter, src *Reader, s *jsonState) (int, error) {
+	var err error
 	src.scratch, err = src.ReadBytes(src.scratch[:0])
 	if err != nil {
-		return
-	}
-	enc := base64.NewEncoder(base64.StdEncoding, dst)
-	nn, err = enc.Write(src.scratch)
-	n += nn
-	if err != nil {
-		return
-	}
-	err = enc.Close()
-	if err != nil {
-		return
-	}
-	err = dst.WriteByte('"')
-	if err != nil {
-		return
+		return 0, err
 	}
-	n++
-	return
+	return s.write(dst, s.appendBin(s.scratch[:0], src.scratch))
 }
 
 // Below (c) The Go Authors, 2009-2014
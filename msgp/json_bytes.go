@@ -2,20 +2,17 @@ package msgp
 
 import (
 	"bufio"
-	"encoding/base64"
-	"encoding/json"
 	"io"
-	"strconv"
 	"time"
 )
 
-var unfuns [_maxtype]func(jsWriter, []byte, []byte) ([]byte, []byte, error)
+var unfuns [_maxtype]func(jsWriter, []byte, *jsonState) ([]byte, error)
 
 func init() {
 
 	// NOTE(pmh): this is best expressed as a jump table,
 	// but gc doesn't do that yet. revisit post-go1.5.
-	unfuns = [_maxtype]func(jsWriter, []byte, []byte) ([]byte, []byte, error){
+	unfuns = [_maxtype]func(jsWriter, []byte, *jsonState) ([]byte, error){
 		StrType:        rwStringBytes,
 		BinType:        rwBytesBytes,
 		MapType:        rwMapBytes,
@@ -39,11 +36,23 @@ func init() {
 // no errors are encountered, the length of the returned
 // slice will be zero.
 func UnmarshalAsJSON(w io.Writer, msg []byte) ([]byte, error) {
+	return defaultJSON.UnmarshalAsJSON(w, msg)
+}
+
+// UnmarshalAsJSON is like the package-level UnmarshalAsJSON,
+// but it translates extensions using the types registered in 'e'.
+func (e *ExtensionRegistry) UnmarshalAsJSON(w io.Writer, msg []byte) ([]byte, error) {
+	o := JSONOptions{Extensions: e}
+	return o.UnmarshalAsJSON(w, msg)
+}
+
+// UnmarshalAsJSON is like the package-level UnmarshalAsJSON,
+// but it renders JSON according to 'o'.
+func (o *JSONOptions) UnmarshalAsJSON(w io.Writer, msg []byte) ([]byte, error) {
 	var (
-		scratch []byte
-		cast    bool
-		dst     jsWriter
-		err     error
+		cast bool
+		dst  jsWriter
+		err  error
 	)
 	if jsw, ok := w.(jsWriter); ok {
 		dst = jsw
@@ -51,8 +60,9 @@ func UnmarshalAsJSON(w io.Writer, msg []byte) ([]byte, error) {
 	} else {
 		dst = bufio.NewWriterSize(w, 512)
 	}
+	s := o.state(DefaultExtensions)
 	for len(msg) > 0 && err == nil {
-		msg, scratch, err = writeNext(dst, msg, scratch)
+		msg, err = writeNext(dst, msg, s)
 	}
 	if !cast && err == nil {
 		err = dst.(*bufio.Writer).Flush()
@@ -60,304 +70,243 @@ func UnmarshalAsJSON(w io.Writer, msg []byte) ([]byte, error) {
 	return msg, err
 }
 
-func writeNext(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func writeNext(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	if len(msg) < 1 {
-		return msg, scratch, ErrShortBytes
+		return msg, ErrShortBytes
 	}
 	t := getType(msg[0])
 	if t == InvalidType {
-		return msg, scratch, InvalidPrefixError(msg[0])
+		return msg, InvalidPrefixError(msg[0])
 	}
 	if t == ExtensionType {
 		et, err := peekExtension(msg)
 		if err != nil {
-			return nil, scratch, err
+			return nil, err
 		}
 		if et == TimeExtension {
 			t = TimeType
 		}
 	}
-	return unfuns[t](w, msg, scratch)
+	return unfuns[t](w, msg, s)
 }
 
-func rwArrayBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwArrayBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	sz, msg, err := ReadArrayHeaderBytes(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
+	}
+	if sz == 0 {
+		_, err = w.WriteString("[]")
+		return msg, err
 	}
 	err = w.WriteByte('[')
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
+	s.depth++
 	for i := uint32(0); i < sz; i++ {
 		if i != 0 {
 			err = w.WriteByte(',')
 			if err != nil {
-				return msg, scratch, err
+				return msg, err
 			}
 		}
-		msg, scratch, err = writeNext(w, msg, scratch)
+		_, err = s.newline(w)
 		if err != nil {
-			return msg, scratch, err
+			return msg, err
 		}
+		msg, err = writeNext(w, msg, s)
+		if err != nil {
+			return msg, err
+		}
+	}
+	s.depth--
+	_, err = s.newline(w)
+	if err != nil {
+		return msg, err
 	}
 	err = w.WriteByte(']')
-	return msg, scratch, err
+	return msg, err
 }
 
-func rwMapBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwMapBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	sz, msg, err := ReadMapHeaderBytes(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
+	}
+	if sz == 0 {
+		_, err = w.WriteString("{}")
+		return msg, err
 	}
 	err = w.WriteByte('{')
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
+	s.depth++
 	for i := uint32(0); i < sz; i++ {
 		if i != 0 {
 			err = w.WriteByte(',')
 			if err != nil {
-				return msg, scratch, err
+				return msg, err
 			}
 		}
-		msg, scratch, err = rwMapKeyBytes(w, msg, scratch)
+		_, err = s.newline(w)
 		if err != nil {
-			return msg, scratch, err
+			return msg, err
 		}
-		err = w.WriteByte(':')
+		msg, err = rwMapKeyBytes(w, msg, s)
 		if err != nil {
-			return msg, scratch, err
+			return msg, err
 		}
-		msg, scratch, err = writeNext(w, msg, scratch)
+		_, err = s.colon(w)
 		if err != nil {
-			return msg, scratch, err
+			return msg, err
+		}
+		msg, err = writeNext(w, msg, s)
+		if err != nil {
+			return msg, err
 		}
 	}
+	s.depth--
+	_, err = s.newline(w)
+	if err != nil {
+		return msg, err
+	}
 	err = w.WriteByte('}')
-	return msg, scratch, err
+	return msg, err
 }
 
-func rwMapKeyBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
-	msg, scratch, err := rwStringBytes(w, msg, scratch)
-	if err != nil {
-		if tperr, ok := err.(TypeError); ok && tperr.Encoded == BinType {
-			return rwBytesBytes(w, msg, scratch)
+func rwMapKeyBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
+	if s.opts.StringifyKeys && len(msg) > 0 && isScalarKey(getType(msg[0])) {
+		var v interface{}
+		var err error
+		v, msg, err = ReadIntfBytes(msg)
+		if err != nil {
+			return msg, err
 		}
+		s.scratch = appendKey(s.scratch[:0], v)
+		_, err = rwquoted(w, s.scratch)
+		return msg, err
 	}
-	return msg, scratch, err
+	// like rwMapKey, write bin keys as strings
+	field, msg, err := ReadMapKeyZC(msg)
+	if err != nil {
+		return msg, err
+	}
+	_, err = rwquoted(w, field)
+	return msg, err
 }
 
-func rwStringBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwStringBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	str, msg, err := ReadStringZC(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
 	_, err = rwquoted(w, str)
-	return msg, scratch, err
+	return msg, err
 }
 
-func rwBytesBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwBytesBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	bts, msg, err := ReadBytesZC(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
-	l := base64.StdEncoding.EncodedLen(len(bts))
-	if cap(scratch) >= l {
-		scratch = scratch[0:l]
-	} else {
-		scratch = make([]byte, l)
-	}
-	base64.StdEncoding.Encode(scratch, bts)
-	err = w.WriteByte('"')
-	if err != nil {
-		return msg, scratch, err
-	}
-	_, err = w.Write(scratch)
-	if err != nil {
-		return msg, scratch, err
-	}
-	err = w.WriteByte('"')
-	return msg, scratch, err
+	_, err = s.write(w, s.appendBin(s.scratch[:0], bts))
+	return msg, err
 }
 
-func rwNullBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwNullBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	msg, err := ReadNilBytes(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
 	_, err = w.Write(null)
-	return msg, scratch, err
+	return msg, err
 }
 
-func rwBoolBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwBoolBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	b, msg, err := ReadBoolBytes(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
 	if b {
 		_, err = w.WriteString("true")
-		return msg, scratch, err
+		return msg, err
 	}
 	_, err = w.WriteString("false")
-	return msg, scratch, err
+	return msg, err
 }
 
-func rwIntBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwIntBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	i, msg, err := ReadInt64Bytes(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
-	scratch = strconv.AppendInt(scratch[0:0], i, 10)
-	_, err = w.Write(scratch)
-	return msg, scratch, err
+	_, err = s.writeInt(w, i)
+	return msg, err
 }
 
-func rwUintBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwUintBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	u, msg, err := ReadUint64Bytes(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
-	scratch = strconv.AppendUint(scratch[0:0], u, 10)
-	_, err = w.Write(scratch)
-	return msg, scratch, err
-}
-
-func rwFloatBytes(w jsWriter, msg []byte, f64 bool, scratch []byte) ([]byte, []byte, error) {
-	var f float64
-	var err error
-	var sz int
-	if f64 {
-		sz = 64
-		f, msg, err = ReadFloat64Bytes(msg)
-	} else {
-		sz = 32
-		var v float32
-		v, msg, err = ReadFloat32Bytes(msg)
-		f = float64(v)
-	}
-	if err != nil {
-		return msg, scratch, err
-	}
-	scratch = strconv.AppendFloat(scratch, f, 'f', -1, sz)
-	_, err = w.Write(scratch)
-	return msg, scratch, err
+	_, err = s.writeUint(w, u)
+	return msg, err
 }
 
-func rwFloat32Bytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwFloat32Bytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	var f float32
 	var err error
 	f, msg, err = ReadFloat32Bytes(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
-	scratch = strconv.AppendFloat(scratch[:0], float64(f), 'f', -1, 32)
-	_, err = w.Write(scratch)
-	return msg, scratch, err
+	_, err = s.writeFloat(w, float64(f), 32)
+	return msg, err
 }
 
-func rwFloat64Bytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwFloat64Bytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	var f float64
 	var err error
 	f, msg, err = ReadFloat64Bytes(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
-	scratch = strconv.AppendFloat(scratch[:0], f, 'f', -1, 64)
-	_, err = w.Write(scratch)
-	return msg, scratch, err
+	_, err = s.writeFloat(w, f, 64)
+	return msg, err
 }
 
-func rwTimeBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwTimeBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	var t time.Time
 	var err error
 	t, msg, err = ReadTimeBytes(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
-	bts, err := t.MarshalJSON()
-	if err != nil {
-		return msg, scratch, err
-	}
-	_, err = w.Write(bts)
-	return msg, scratch, err
+	_, err = s.writeTime(w, t)
+	return msg, err
 }
 
-func rwExtensionBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwExtensionBytes(w jsWriter, msg []byte, s *jsonState) ([]byte, error) {
 	var err error
 	var et int8
 	et, err = peekExtension(msg)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
 
 	// if it's time.Time
 	if et == TimeExtension {
-		var tm time.Time
-		tm, msg, err = ReadTimeBytes(msg)
-		if err != nil {
-			return msg, scratch, err
-		}
-		bts, err := tm.MarshalJSON()
-		if err != nil {
-			return msg, scratch, err
-		}
-		_, err = w.Write(bts)
-		return msg, scratch, err
-	}
-
-	// if the extension is registered,
-	// use its canonical JSON form
-	if f, ok := extensionReg[et]; ok {
-		e := f()
-		msg, err = ReadExtensionBytes(msg, e)
-		if err != nil {
-			return msg, scratch, err
-		}
-		bts, err := json.Marshal(e)
-		if err != nil {
-			return msg, scratch, err
-		}
-		_, err = w.Write(bts)
-		return msg, scratch, err
+		return rwTimeBytes(w, msg, s)
 	}
 
-	// otherwise, write `{"type": <num>, "data": "<base64data>"}`
 	r := RawExtension{}
 	r.Type = et
 	msg, err = ReadExtensionBytes(msg, &r)
 	if err != nil {
-		return msg, scratch, err
+		return msg, err
 	}
-	scratch, err = writeExt(w, r, scratch)
-	return msg, scratch, err
-}
-
-func writeExt(w jsWriter, r RawExtension, scratch []byte) ([]byte, error) {
-	_, err := w.WriteString(`{"type":`)
-	if err != nil {
-		return scratch, err
-	}
-	scratch = strconv.AppendInt(scratch[0:0], int64(r.Type), 10)
-	_, err = w.Write(scratch)
-	if err != nil {
-		return scratch, err
-	}
-	_, err = w.WriteString(`,"data":"`)
-	if err != nil {
-		return scratch, err
-	}
-	l := base64.StdEncoding.EncodedLen(len(r.Data))
-	if cap(scratch) >= l {
-		scratch = scratch[0:l]
-	} else {
-		scratch = make([]byte, l)
-	}
-	base64.StdEncoding.Encode(scratch, r.Data)
-	_, err = w.Write(scratch)
-	if err != nil {
-		return scratch, err
-	}
-	_, err = w.WriteString(`"}`)
-	return scratch, err
+	_, err = s.writeExt(w, &r)
+	return msg, err
 }
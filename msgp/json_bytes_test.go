@@ -119,3 +119,31 @@ func BenchmarkUnmarshalAsJSON(b *testing.B) {
 		UnmarshalAsJSON(&js, bts)
 	}
 }
+
+func TestUnmarshalJSONBinKeys(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewWriter(&buf)
+	enc.WriteMapHeader(2)
+	enc.WriteBytes([]byte("key"))
+	enc.WriteInt(1)
+	enc.WriteString("other")
+	enc.WriteInt(2)
+	enc.Flush()
+
+	var js bytes.Buffer
+	left, err := UnmarshalAsJSON(&js, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 0 {
+		t.Errorf("%d bytes left over", len(left))
+	}
+
+	var want bytes.Buffer
+	if _, err := CopyToJSON(&want, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if js.String() != want.String() {
+		t.Errorf("UnmarshalAsJSON: got %s; CopyToJSON: %s", js.Bytes(), want.Bytes())
+	}
+}
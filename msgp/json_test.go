@@ -3,8 +3,11 @@ package msgp
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"math"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCopyJSON(t *testing.T) {
@@ -69,6 +72,191 @@ func TestCopyJSON(t *testing.T) {
 	}
 }
 
+// translateJSON renders 'msg' with both CopyToJSON
+// and UnmarshalAsJSON, and checks that they agree
+func translateJSON(t *testing.T, o *JSONOptions, msg []byte) (string, error) {
+	var stream, bts bytes.Buffer
+	_, serr := o.CopyToJSON(&stream, bytes.NewReader(msg))
+	_, berr := o.UnmarshalAsJSON(&bts, msg)
+	if (serr == nil) != (berr == nil) {
+		t.Errorf("CopyToJSON returned %v; UnmarshalAsJSON returned %v", serr, berr)
+	}
+	if serr == nil && stream.String() != bts.String() {
+		t.Errorf("CopyToJSON wrote %s; UnmarshalAsJSON wrote %s", stream.String(), bts.String())
+	}
+	return bts.String(), berr
+}
+
+func TestJSONOptions(t *testing.T) {
+	ts := time.Date(2020, 5, 1, 12, 30, 0, 0, time.UTC)
+	cases := []struct {
+		name string
+		opts JSONOptions
+		in   func(w *Writer)
+		want string
+	}{
+		{
+			name: "default",
+			in: func(w *Writer) {
+				w.WriteMapHeader(2)
+				w.WriteString("f")
+				w.WriteFloat32(1.1)
+				w.WriteString("b")
+				w.WriteBytes([]byte{0xde, 0xad})
+			},
+			want: `{"f":1.1,"b":"3q0="}`,
+		},
+		{
+			name: "indent",
+			opts: JSONOptions{Prefix: "\t", Indent: "  "},
+			in: func(w *Writer) {
+				w.WriteMapHeader(3)
+				w.WriteString("a")
+				w.WriteArrayHeader(2)
+				w.WriteInt(1)
+				w.WriteInt(2)
+				w.WriteString("e")
+				w.WriteArrayHeader(0)
+				w.WriteString("m")
+				w.WriteMapHeader(0)
+			},
+			want: "{\n\t  \"a\": [\n\t    1,\n\t    2\n\t  ],\n\t  \"e\": [],\n\t  \"m\": {}\n\t}",
+		},
+		{
+			name: "hex",
+			opts: JSONOptions{Bin: BinHex},
+			in:   func(w *Writer) { w.WriteBytes([]byte{0xde, 0xad}) },
+			want: `"dead"`,
+		},
+		{
+			name: "array",
+			opts: JSONOptions{Bin: BinArray},
+			in:   func(w *Writer) { w.WriteBytes([]byte{0xde, 0xad}) },
+			want: `[222,173]`,
+		},
+		{
+			name: "time",
+			opts: JSONOptions{TimeFormat: time.RFC1123},
+			in:   func(w *Writer) { w.WriteTime(ts) },
+			want: `"Fri, 01 May 2020 12:30:00 UTC"`,
+		},
+		{
+			name: "nan null",
+			opts: JSONOptions{NonFinite: NonFiniteNull},
+			in: func(w *Writer) {
+				w.WriteArrayHeader(2)
+				w.WriteFloat64(math.NaN())
+				w.WriteFloat32(float32(math.Inf(1)))
+			},
+			want: `[null,null]`,
+		},
+		{
+			name: "nan string",
+			opts: JSONOptions{NonFinite: NonFiniteString},
+			in: func(w *Writer) {
+				w.WriteArrayHeader(2)
+				w.WriteFloat64(math.NaN())
+				w.WriteFloat64(math.Inf(-1))
+			},
+			want: `["NaN","-Inf"]`,
+		},
+		{
+			name: "keys",
+			opts: JSONOptions{StringifyKeys: true},
+			in: func(w *Writer) {
+				w.WriteMapHeader(3)
+				w.WriteInt(-1)
+				w.WriteBool(true)
+				w.WriteUint(2)
+				w.WriteNil()
+				w.WriteFloat64(0.5)
+				w.WriteString("x")
+			},
+			want: `{"-1":true,"2":null,"0.5":"x"}`,
+		},
+		{
+			name: "large ints",
+			opts: JSONOptions{QuoteLargeInts: true},
+			in: func(w *Writer) {
+				w.WriteArrayHeader(4)
+				w.WriteInt64(1<<53 - 1)
+				w.WriteInt64(-1 << 53)
+				w.WriteUint64(math.MaxUint64)
+				w.WriteUint64(7)
+			},
+			want: `[9007199254740991,"-9007199254740992","18446744073709551615",7]`,
+		},
+		{
+			name: "raw extension",
+			in: func(w *Writer) {
+				w.WriteExtension(&RawExtension{Type: 33, Data: []byte{1, 2}})
+			},
+			want: `{"type":33,"data":"AQI="}`,
+		},
+		{
+			name: "extension hook",
+			opts: JSONOptions{
+				Bin: BinHex,
+				Extension: func(typ int8, data []byte) ([]byte, error) {
+					if typ != 34 {
+						return nil, nil
+					}
+					return []byte(fmt.Sprintf(`{"len":%d}`, len(data))), nil
+				},
+			},
+			in: func(w *Writer) {
+				w.WriteArrayHeader(2)
+				w.WriteExtension(&RawExtension{Type: 34, Data: []byte{1, 2}})
+				w.WriteExtension(&RawExtension{Type: 33, Data: []byte{1, 2}})
+			},
+			want: `[{"len":2},{"type":33,"data":"0102"}]`,
+		},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		tc.in(w)
+		w.Flush()
+		out, err := translateJSON(t, &tc.opts, buf.Bytes())
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if out != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.name, out, tc.want)
+		}
+		if !json.Valid([]byte(out)) {
+			t.Errorf("%s: invalid JSON %s", tc.name, out)
+		}
+	}
+}
+
+func TestJSONRejects(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteFloat64(math.NaN())
+	w.Flush()
+	_, err := translateJSON(t, &JSONOptions{NonFinite: NonFiniteReject}, buf.Bytes())
+	if _, ok := err.(NonFiniteError); !ok {
+		t.Errorf("NaN: got error %v; want a NonFiniteError", err)
+	}
+	// without options, non-finite floats are
+	// written as they always have been
+	if out, err := translateJSON(t, &JSONOptions{}, buf.Bytes()); out != "NaN" || err != nil {
+		t.Errorf("NaN: got %s, %v; want NaN", out, err)
+	}
+
+	buf.Reset()
+	w.WriteMapHeader(1)
+	w.WriteInt(1)
+	w.WriteInt(2)
+	w.Flush()
+	_, err = translateJSON(t, &JSONOptions{}, buf.Bytes())
+	if _, ok := err.(TypeError); !ok {
+		t.Errorf("int key: got error %v; want a TypeError", err)
+	}
+}
+
 func BenchmarkCopyToJSON(b *testing.B) {
 	var buf bytes.Buffer
 	enc := NewWriter(&buf)
@@ -0,0 +1,96 @@
+package msgp
+
+import (
+	"os"
+)
+
+// readMapped calls 'fn' with the first 'size'
+// bytes of 'file', which are only valid
+// during the call.
+func readMapped(file *os.File, size int64, fn func(data []byte) error) error {
+	data, err := mapFile(file, size)
+	if err != nil {
+		return err
+	}
+	err = fn(data)
+	uerr := unmapFile(data)
+	if err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// MappedFile is a read-only memory mapping of
+// a file of MessagePack objects, which stays
+// valid until Close is called. Unlike ReadFile,
+// it can be used with the zero-copy functions,
+// like Locate, LocatePath, Skip and ReadStringZC,
+// so that lookups don't have to decode the file.
+//
+// Files are only memory-mapped on Linux, Darwin
+// and the BSDs, outside of App Engine. On every
+// other platform the file is read into memory.
+//
+// Slices returned from a MappedFile, or from
+// functions called on its bytes, must not be
+// used after Close. (Doing so will cause a
+// fault.) The bytes must never be modified.
+type MappedFile struct {
+	data []byte
+}
+
+// MapFile maps the contents of 'file'. The
+// file may be closed once MapFile returns.
+func MapFile(file *os.File) (*MappedFile, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, err := mapFile(file, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	return &MappedFile{data: data}, nil
+}
+
+// OpenMappedFile maps the contents
+// of the file at 'path'.
+func OpenMappedFile(path string) (*MappedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return MapFile(file)
+}
+
+// Bytes returns the contents of the file.
+func (m *MappedFile) Bytes() []byte { return m.data }
+
+// Len returns the size of the file.
+func (m *MappedFile) Len() int { return len(m.data) }
+
+// Locate returns the field with key 'key' in
+// the map at the start of the file. See Locate.
+func (m *MappedFile) Locate(key string) []byte { return Locate(key, m.data) }
+
+// LocatePath returns the object at 'path' in
+// the first object in the file. See LocatePath.
+func (m *MappedFile) LocatePath(path ...string) []byte { return LocatePath(m.data, path...) }
+
+// Unmarshal decodes the first object in
+// the file into 'dst', and returns the
+// bytes that follow it. If 'dst' keeps
+// zero-copy references to the file,
+// they are only valid until Close.
+func (m *MappedFile) Unmarshal(dst Unmarshaler) ([]byte, error) {
+	return dst.UnmarshalMsg(m.data)
+}
+
+// Close releases the mapping. It is
+// safe to call Close more than once.
+func (m *MappedFile) Close() error {
+	data := m.data
+	m.data = nil
+	return unmapFile(data)
+}
@@ -0,0 +1,95 @@
+package msgp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMappedFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "msgp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	w := NewWriter(f)
+	w.WriteMapHeader(2)
+	w.WriteString("name")
+	w.WriteString("index")
+	w.WriteString("users")
+	w.WriteArrayHeader(2)
+	for _, name := range []string{"alice", "bob"} {
+		w.WriteMapHeader(1)
+		w.WriteString("name")
+		w.WriteString(name)
+	}
+	w.WriteString("second object")
+	w.Flush()
+	f.Close()
+
+	m, err := OpenMappedFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	name, _, err := ReadStringZC(m.Locate("name"))
+	if err != nil || string(name) != "index" {
+		t.Errorf("Locate: %q, %v", name, err)
+	}
+	name, _, err = ReadStringZC(m.LocatePath("users", "1", "name"))
+	if err != nil || string(name) != "bob" {
+		t.Errorf("LocatePath: %q, %v", name, err)
+	}
+	if b := m.LocatePath("users", "2", "name"); len(b) != 0 {
+		t.Errorf("LocatePath past the end of an array: %x", b)
+	}
+	rest, err := Skip(m.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Raw
+	if rest, err := m.Unmarshal(&r); err != nil || len(rest) != len(AppendString(nil, "second object")) {
+		t.Errorf("Unmarshal: %d bytes left, %v", len(rest), err)
+	}
+	if s, _, err := ReadStringBytes(rest); err != nil || s != "second object" {
+		t.Errorf("second object: %q, %v", s, err)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil || m.Len() != 0 {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestLocatePath(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteArrayHeader(2)
+	w.WriteInt(1)
+	w.WriteMapHeader(1)
+	w.WriteString("a")
+	w.WriteArrayHeader(1)
+	w.WriteString("b")
+	w.Flush()
+	raw := buf.Bytes()
+
+	if s, _, err := ReadStringBytes(LocatePath(raw, "1", "a", "0")); err != nil || s != "b" {
+		t.Errorf("got %q, %v", s, err)
+	}
+	if got := LocatePath(raw); !bytes.Equal(got, raw) {
+		t.Errorf("empty path: %x", got)
+	}
+	if got := LocatePath(raw, "0"); !bytes.Equal(got, AppendInt(nil, 1)) {
+		t.Errorf("LocatePath(raw, \"0\") = %x", got)
+	}
+	for _, path := range [][]string{{"x"}, {"-1"}, {"2"}, {"1", "b"}, {"0", "a"}} {
+		if got := LocatePath(raw, path...); len(got) != 0 {
+			t.Errorf("LocatePath(raw, %q) = %x", path, got)
+		}
+	}
+}
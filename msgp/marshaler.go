@@ -0,0 +1,119 @@
+package msgp
+
+import (
+	"encoding"
+)
+
+// WriteBinaryMarshaler writes the result of
+// m.MarshalBinary() as a MessagePack 'bin' object.
+func (mw *Writer) WriteBinaryMarshaler(m encoding.BinaryMarshaler) error {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return mw.WriteBytes(data)
+}
+
+// WriteTextMarshaler writes the result of
+// m.MarshalText() as a MessagePack 'str' object.
+func (mw *Writer) WriteTextMarshaler(m encoding.TextMarshaler) error {
+	data, err := m.MarshalText()
+	if err != nil {
+		return err
+	}
+	return mw.WriteStringFromBytes(data)
+}
+
+// ReadBinaryUnmarshaler reads a MessagePack 'bin' object
+// and passes its contents to u.UnmarshalBinary(). The slice
+// passed to UnmarshalBinary is only valid for the duration
+// of the call.
+func (m *Reader) ReadBinaryUnmarshaler(u encoding.BinaryUnmarshaler) (err error) {
+	m.scratch, err = m.ReadBytes(m.scratch[:0])
+	if err != nil {
+		return
+	}
+	return u.UnmarshalBinary(m.scratch)
+}
+
+// ReadTextUnmarshaler reads a MessagePack 'str' object
+// and passes its contents to u.UnmarshalText(). The slice
+// passed to UnmarshalText is only valid for the duration
+// of the call.
+func (m *Reader) ReadTextUnmarshaler(u encoding.TextUnmarshaler) (err error) {
+	m.scratch, err = m.ReadStringAsBytes(m.scratch[:0])
+	if err != nil {
+		return
+	}
+	return u.UnmarshalText(m.scratch)
+}
+
+// AppendBinaryMarshaler appends the result of
+// m.MarshalBinary() to the slice as a MessagePack
+// 'bin' object.
+func AppendBinaryMarshaler(b []byte, m encoding.BinaryMarshaler) ([]byte, error) {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return b, err
+	}
+	return AppendBytes(b, data), nil
+}
+
+// AppendTextMarshaler appends the result of
+// m.MarshalText() to the slice as a MessagePack
+// 'str' object.
+func AppendTextMarshaler(b []byte, m encoding.TextMarshaler) ([]byte, error) {
+	data, err := m.MarshalText()
+	if err != nil {
+		return b, err
+	}
+	return AppendStringFromBytes(b, data), nil
+}
+
+// ReadBinaryUnmarshalerBytes reads a MessagePack 'bin' object
+// from 'b', passes its contents to u.UnmarshalBinary(), and
+// returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not 'bin')
+// - any error returned by u.UnmarshalBinary()
+func ReadBinaryUnmarshalerBytes(b []byte, u encoding.BinaryUnmarshaler) ([]byte, error) {
+	v, o, err := ReadBytesZC(b)
+	if err != nil {
+		return b, err
+	}
+	return o, u.UnmarshalBinary(v)
+}
+
+// ReadTextUnmarshalerBytes reads a MessagePack 'str' object
+// from 'b', passes its contents to u.UnmarshalText(), and
+// returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not 'str')
+// - any error returned by u.UnmarshalText()
+func ReadTextUnmarshalerBytes(b []byte, u encoding.TextUnmarshaler) ([]byte, error) {
+	v, o, err := ReadStringZC(b)
+	if err != nil {
+		return b, err
+	}
+	return o, u.UnmarshalText(v)
+}
+
+// BinaryMarshalerSize returns the encoded size
+// of m. Since the size of the output of MarshalBinary
+// is not known in advance, m is marshaled in order
+// to compute it.
+func BinaryMarshalerSize(m encoding.BinaryMarshaler) int {
+	data, _ := m.MarshalBinary()
+	return BytesPrefixSize + len(data)
+}
+
+// TextMarshalerSize returns the encoded size
+// of m. Since the size of the output of MarshalText
+// is not known in advance, m is marshaled in order
+// to compute it.
+func TextMarshalerSize(m encoding.TextMarshaler) int {
+	data, _ := m.MarshalText()
+	return StringPrefixSize + len(data)
+}
@@ -0,0 +1,120 @@
+package msgp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// testMarshaler implements both the
+// binary and text marshaler interfaces
+type testMarshaler struct {
+	data string
+}
+
+var errTestMarshaler = errors.New("testMarshaler: empty")
+
+func (t *testMarshaler) MarshalBinary() ([]byte, error) {
+	if t.data == "" {
+		return nil, errTestMarshaler
+	}
+	return []byte(t.data), nil
+}
+
+func (t *testMarshaler) UnmarshalBinary(b []byte) error {
+	t.data = string(b)
+	return nil
+}
+
+func (t *testMarshaler) MarshalText() ([]byte, error) { return t.MarshalBinary() }
+
+func (t *testMarshaler) UnmarshalText(b []byte) error { return t.UnmarshalBinary(b) }
+
+func TestReadWriteMarshalers(t *testing.T) {
+	var buf bytes.Buffer
+	en := NewWriter(&buf)
+	dc := NewReader(&buf)
+
+	in := testMarshaler{data: "hello"}
+	if err := en.WriteBinaryMarshaler(&in); err != nil {
+		t.Fatal(err)
+	}
+	if err := en.WriteTextMarshaler(&in); err != nil {
+		t.Fatal(err)
+	}
+	en.Flush()
+
+	if n := BinaryMarshalerSize(&in); n < buf.Len()/2 {
+		t.Errorf("BinaryMarshalerSize() = %d; too small", n)
+	}
+
+	var out testMarshaler
+	if typ, _ := dc.NextType(); typ != BinType {
+		t.Errorf("got type %s; want %s", typ, BinType)
+	}
+	if err := dc.ReadBinaryUnmarshaler(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("got %q; want %q", out.data, in.data)
+	}
+	out = testMarshaler{}
+	if typ, _ := dc.NextType(); typ != StrType {
+		t.Errorf("got type %s; want %s", typ, StrType)
+	}
+	if err := dc.ReadTextUnmarshaler(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("got %q; want %q", out.data, in.data)
+	}
+
+	if err := en.WriteBinaryMarshaler(&testMarshaler{}); err != errTestMarshaler {
+		t.Errorf("got error %v; want %v", err, errTestMarshaler)
+	}
+}
+
+func TestReadWriteMarshalersBytes(t *testing.T) {
+	in := testMarshaler{data: "hello"}
+	b, err := AppendBinaryMarshaler(nil, &in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = AppendTextMarshaler(b, &in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sz := BinaryMarshalerSize(&in) + TextMarshalerSize(&in); sz < len(b) {
+		t.Errorf("sizes %d < encoded length %d", sz, len(b))
+	}
+
+	var out testMarshaler
+	b, err = ReadBinaryUnmarshalerBytes(b, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("got %q; want %q", out.data, in.data)
+	}
+	out = testMarshaler{}
+	b, err = ReadTextUnmarshalerBytes(b, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("got %q; want %q", out.data, in.data)
+	}
+	if len(b) != 0 {
+		t.Errorf("%d bytes left over", len(b))
+	}
+
+	if _, err := AppendTextMarshaler(nil, &testMarshaler{}); err != errTestMarshaler {
+		t.Errorf("got error %v; want %v", err, errTestMarshaler)
+	}
+
+	// a 'str' is not a 'bin'
+	b = AppendString(nil, "hello")
+	if _, err := ReadBinaryUnmarshalerBytes(b, &out); err == nil {
+		t.Error("expected a type error")
+	}
+}
@@ -0,0 +1,57 @@
+//go:build go1.18
+// +build go1.18
+
+package msgp
+
+import (
+	"net/netip"
+)
+
+// A netip.Addr is encoded as a MessagePack
+// 'bin' object holding the result of its
+// MarshalBinary method: the 4- or 16-byte address,
+// followed by the zone (if any). The zero Addr
+// is encoded as an empty 'bin'.
+
+// WriteAddr writes a netip.Addr to the writer
+func (mw *Writer) WriteAddr(a netip.Addr) error {
+	data, _ := a.MarshalBinary()
+	return mw.WriteBytes(data)
+}
+
+// ReadAddr reads a netip.Addr from the reader
+func (m *Reader) ReadAddr() (a netip.Addr, err error) {
+	m.scratch, err = m.ReadBytes(m.scratch[:0])
+	if err != nil {
+		return
+	}
+	err = a.UnmarshalBinary(m.scratch)
+	return
+}
+
+// AppendAddr appends a netip.Addr to the slice
+func AppendAddr(b []byte, a netip.Addr) []byte {
+	data, _ := a.MarshalBinary()
+	return AppendBytes(b, data)
+}
+
+// ReadAddrBytes reads a netip.Addr from 'b'
+// and returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not 'bin')
+// - an error from (*netip.Addr).UnmarshalBinary
+func ReadAddrBytes(b []byte) (a netip.Addr, o []byte, err error) {
+	var v []byte
+	v, o, err = ReadBytesZC(b)
+	if err != nil {
+		return
+	}
+	err = a.UnmarshalBinary(v)
+	return
+}
+
+// AddrSize returns the encoded size of a
+func AddrSize(a netip.Addr) int {
+	return BytesPrefixSize + 16 + len(a.Zone())
+}
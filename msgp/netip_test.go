@@ -0,0 +1,45 @@
+//go:build go1.18
+// +build go1.18
+
+package msgp
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestReadWriteAddr(t *testing.T) {
+	addrs := []netip.Addr{{}, netip.MustParseAddr("10.1.2.3"), netip.MustParseAddr("fe80::1%eth0")}
+	var buf bytes.Buffer
+	en := NewWriter(&buf)
+	dc := NewReader(&buf)
+	for _, in := range addrs {
+		buf.Reset()
+		if err := en.WriteAddr(in); err != nil {
+			t.Fatal(err)
+		}
+		en.Flush()
+		if buf.Len() > AddrSize(in) {
+			t.Errorf("%s: AddrSize() = %d; encoded %d bytes", in, AddrSize(in), buf.Len())
+		}
+		b := AppendAddr(nil, in)
+		if !bytes.Equal(b, buf.Bytes()) {
+			t.Errorf("%s: AppendAddr and WriteAddr disagree", in)
+		}
+		out, err := dc.ReadAddr()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != in {
+			t.Errorf("got %s; want %s", out, in)
+		}
+		out, b, err = ReadAddrBytes(b)
+		if err != nil || len(b) != 0 {
+			t.Fatal(err, len(b))
+		}
+		if out != in {
+			t.Errorf("got %s; want %s", out, in)
+		}
+	}
+}
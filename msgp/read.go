@@ -79,6 +79,7 @@ func (t Type) String() string {
 }
 
 func freeR(m *Reader) {
+	m.Extensions = nil
 	readerPool.Put(m)
 }
 
@@ -137,10 +138,23 @@ type Reader struct {
 	// is stateless; all the
 	// buffering is done
 	// within R.
-	R       *fwd.Reader
+	R *fwd.Reader
+
+	// Extensions is the registry used to
+	// decode extensions in ReadIntf and WriteToJSON.
+	// If it is nil, DefaultExtensions is used.
+	Extensions *ExtensionRegistry
+
 	scratch []byte
 }
 
+func (m *Reader) extensions() *ExtensionRegistry {
+	if m.Extensions != nil {
+		return m.Extensions
+	}
+	return DefaultExtensions
+}
+
 // Read implements `io.Reader`
 func (m *Reader) Read(p []byte) (int, error) {
 	return m.R.Read(p)
@@ -1161,6 +1175,15 @@ func (m *Reader) ReadTime() (t time.Time, err error) {
 	return
 }
 
+// ReadDuration reads a time.Duration object from the reader.
+// Durations are encoded as an int64 number of nanoseconds.
+func (m *Reader) ReadDuration() (d time.Duration, err error) {
+	var i int64
+	i, err = m.ReadInt64()
+	d = time.Duration(i)
+	return
+}
+
 // ReadIntf reads out the next object as a raw interface{}.
 // Arrays are decoded as []interface{}, and maps are decoded
 // as map[string]interface{}. Integers are decoded as int64
@@ -1210,7 +1233,7 @@ func (m *Reader) ReadIntf() (i interface{}, err error) {
 		if err != nil {
 			return
 		}
-		f, ok := extensionReg[t]
+		f, ok := m.extensions().Lookup(t)
 		if ok {
 			e := f()
 			err = m.ReadExtension(e)
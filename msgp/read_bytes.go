@@ -194,14 +194,14 @@ func ReadMapHeaderBytes(b []byte) (sz uint32, o []byte, err error) {
 // - ErrShortBytes (too few bytes)
 // - TypeError{} (not a str or bin)
 func ReadMapKeyZC(b []byte) ([]byte, []byte, error) {
-	o, b, err := ReadStringZC(b)
+	o, x, err := ReadStringZC(b)
 	if err != nil {
 		if tperr, ok := err.(TypeError); ok && tperr.Encoded == BinType {
 			return ReadBytesZC(b)
 		}
 		return nil, b, err
 	}
-	return o, b, nil
+	return o, x, nil
 }
 
 // ReadArrayHeaderBytes attempts to read
@@ -879,10 +879,29 @@ func ReadTimeBytes(b []byte) (t time.Time, o []byte, err error) {
 	return
 }
 
+// ReadDurationBytes reads a time.Duration,
+// encoded as an int64 number of nanoseconds,
+// from 'b' and returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not an int)
+func ReadDurationBytes(b []byte) (d time.Duration, o []byte, err error) {
+	var i int64
+	i, o, err = ReadInt64Bytes(b)
+	d = time.Duration(i)
+	return
+}
+
 // ReadMapStrIntfBytes reads a map[string]interface{}
 // out of 'b' and returns the map and remaining bytes.
 // If 'old' is non-nil, the values will be read into that map.
 func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (v map[string]interface{}, o []byte, err error) {
+	return DefaultExtensions.ReadMapStrIntfBytes(b, old)
+}
+
+// ReadMapStrIntfBytes is like the package-level ReadMapStrIntfBytes,
+// but it decodes extensions using the types registered in 'e'.
+func (e *ExtensionRegistry) ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (v map[string]interface{}, o []byte, err error) {
 	var sz uint32
 	o = b
 	sz, o, err = ReadMapHeaderBytes(o)
@@ -911,7 +930,7 @@ func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (v map[string]int
 			return
 		}
 		var val interface{}
-		val, o, err = ReadIntfBytes(o)
+		val, o, err = e.ReadIntfBytes(o)
 		if err != nil {
 			return
 		}
@@ -924,6 +943,12 @@ func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (v map[string]int
 // the next object out of 'b' as a raw interface{} and
 // return the remaining bytes.
 func ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
+	return DefaultExtensions.ReadIntfBytes(b)
+}
+
+// ReadIntfBytes is like the package-level ReadIntfBytes,
+// but it decodes extensions using the types registered in 'e'.
+func (e *ExtensionRegistry) ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
 	if len(b) < 1 {
 		err = ErrShortBytes
 		return
@@ -933,7 +958,7 @@ func ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
 
 	switch k {
 	case MapType:
-		i, o, err = ReadMapStrIntfBytes(b, nil)
+		i, o, err = e.ReadMapStrIntfBytes(b, nil)
 		return
 
 	case ArrayType:
@@ -945,7 +970,7 @@ func ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
 		j := make([]interface{}, int(sz))
 		i = j
 		for d := range j {
-			j[d], o, err = ReadIntfBytes(o)
+			j[d], o, err = e.ReadIntfBytes(o)
 			if err != nil {
 				return
 			}
@@ -992,18 +1017,18 @@ func ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
 		}
 		// use a user-defined extension,
 		// if it's been registered
-		f, ok := extensionReg[t]
+		f, ok := e.Lookup(t)
 		if ok {
-			e := f()
-			o, err = ReadExtensionBytes(b, e)
-			i = e
+			x := f()
+			o, err = ReadExtensionBytes(b, x)
+			i = x
 			return
 		}
 		// last resort is a raw extension
-		e := RawExtension{}
-		e.Type = int8(t)
-		o, err = ReadExtensionBytes(b, &e)
-		i = &e
+		x := RawExtension{}
+		x.Type = int8(t)
+		o, err = ReadExtensionBytes(b, &x)
+		i = &x
 		return
 
 	case NilType:
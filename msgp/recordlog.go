@@ -0,0 +1,333 @@
+package msgp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// recordLogOptions are the frame options of a record log file
+var recordLogOptions = FrameOptions{
+	Checksum: true,
+	Magic:    []byte("MSGPLOG"),
+	Version:  1,
+	MaxSize:  DefaultMaxFrameSize,
+}
+
+// recordLogStart is the offset of the first record
+var recordLogStart = int64(len(recordLogOptions.Magic) + 1)
+
+// RecordLog is an append-only file of records,
+// each of which is one MessagePack object stored
+// in a checksummed frame. (See FrameWriter.)
+// Records are numbered from zero in the order
+// that they were appended.
+//
+// When a log is opened, any partial or corrupt
+// records at the end of the file, such as those
+// left by a crash during Append, are removed.
+// Corrupt records followed by good ones are
+// skipped, and are removed by Compact.
+// Records are only durable once Sync returns.
+// Records can be at most DefaultMaxFrameSize
+// bytes long.
+//
+// A RecordLog is not safe for concurrent use.
+type RecordLog struct {
+	file      *os.File
+	path      string
+	index     []int64 // offset of each record
+	end       int64   // offset after the last record
+	discarded int64
+	w         *FrameWriter
+	buf       []byte
+}
+
+// OpenRecordLog opens the record log at 'path',
+// creating it if it does not exist.
+func OpenRecordLog(path string) (*RecordLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l := &RecordLog{file: file, path: path}
+	if err := l.recover(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// recover builds the index, skipping corrupt records,
+// and truncates the file after the last good record
+func (l *RecordLog) recover() error {
+	stat, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+	l.index = l.index[:0]
+	l.end = recordLogStart
+	l.discarded = 0
+	if size < recordLogStart {
+		// a new file, or a crash while writing the header
+		return l.create(size)
+	}
+	err = readMapped(l.file, size, func(data []byte) error {
+		fr := NewFrameReader(bytes.NewReader(data), recordLogOptions)
+		if v, err := fr.Version(); err != nil {
+			return fmt.Errorf("msgp: %s is not a record log: %s", l.path, err)
+		} else if v != recordLogOptions.Version {
+			return fmt.Errorf("msgp: %s has unsupported record log version %d", l.path, v)
+		}
+		for {
+			off := fr.Offset()
+			_, err := fr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if _, ok := err.(FrameError); err != nil && !ok {
+				// a partial record at the end of the
+				// file, which is removed below
+				return nil
+			}
+			if err != nil {
+				// skip to the next good record; if there
+				// isn't one, the rest of the file is
+				// corrupt, and is removed below
+				if _, err := fr.Resync(); err != nil || fr.Offset() == off {
+					return nil
+				}
+				continue
+			}
+			if off != l.end {
+				l.discarded += off - l.end
+			}
+			l.index = append(l.index, off)
+			l.end = fr.Offset()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if l.end < size {
+		l.discarded += size - l.end
+		if err := l.file.Truncate(l.end); err != nil {
+			return err
+		}
+	}
+	return l.seekEnd()
+}
+
+// create writes the header of a new log, replacing
+// the first 'size' bytes of the file, which must be
+// a partial header
+func (l *RecordLog) create(size int64) error {
+	hdr := append(append([]byte{}, recordLogOptions.Magic...), recordLogOptions.Version)
+	if size > 0 {
+		p := make([]byte, size)
+		if _, err := l.file.ReadAt(p, 0); err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(hdr, p) {
+			return fmt.Errorf("msgp: %s is not a record log", l.path)
+		}
+		l.discarded = size
+	}
+	if _, err := l.file.WriteAt(hdr, 0); err != nil {
+		return err
+	}
+	return l.seekEnd()
+}
+
+// seekEnd prepares to append records at l.end
+func (l *RecordLog) seekEnd() error {
+	if _, err := l.file.Seek(l.end, io.SeekStart); err != nil {
+		return err
+	}
+	if l.w == nil {
+		l.w = NewFrameWriter(l.file, recordLogOptions)
+	} else {
+		l.w.w.Reset(l.file)
+	}
+	l.w.started = true
+	return nil
+}
+
+// Len returns the number of records in the log.
+func (l *RecordLog) Len() int { return len(l.index) }
+
+// Discarded returns the number of bytes in partial or
+// corrupt records that were skipped or removed when
+// the log was opened.
+func (l *RecordLog) Discarded() int64 { return l.discarded }
+
+// Append appends 'v' to the log, and
+// returns its record number.
+func (l *RecordLog) Append(v MarshalSizer) (int, error) {
+	var err error
+	l.buf, err = v.MarshalMsg(Require(l.buf[:0], v.Msgsize()))
+	if err != nil {
+		return 0, err
+	}
+	return l.AppendRaw(l.buf)
+}
+
+// AppendRaw appends 'msg', which should be one
+// MessagePack object, to the log, and returns
+// its record number.
+func (l *RecordLog) AppendRaw(msg []byte) (int, error) {
+	if len(msg) > int(recordLogOptions.MaxSize) {
+		return 0, fmt.Errorf("msgp: record of %d bytes is larger than the maximum of %d", len(msg), recordLogOptions.MaxSize)
+	}
+	err := l.w.WriteFrame(msg)
+	if err == nil {
+		err = l.w.Flush()
+	}
+	if err != nil {
+		// drop whatever was written
+		if terr := l.file.Truncate(l.end); terr == nil {
+			l.seekEnd()
+		}
+		return 0, err
+	}
+	n := len(l.index)
+	l.index = append(l.index, l.end)
+	l.end += int64(recordLogOptions.frameSize(len(msg)))
+	return n, nil
+}
+
+// ReadRaw returns record 'n'. The returned
+// slice is only valid until the next call.
+func (l *RecordLog) ReadRaw(n int) ([]byte, error) {
+	if n < 0 || n >= len(l.index) {
+		return nil, fmt.Errorf("msgp: record %d is out of range [0, %d)", n, len(l.index))
+	}
+	off, next := l.index[n], l.end
+	if n+1 < len(l.index) {
+		next = l.index[n+1]
+	}
+	p := Require(l.buf[:0], int(next-off))[:next-off]
+	l.buf = p
+	if _, err := l.file.ReadAt(p, off); err != nil {
+		return nil, err
+	}
+	// the record may be followed by skipped bytes
+	hs := recordLogOptions.headerSize()
+	sz := int(big.Uint32(p[2:]))
+	if !bytes.Equal(p[:2], frameMarker) || recordLogOptions.frameSize(sz) > len(p) {
+		return nil, FrameError{Offset: off, Reason: "bad frame header"}
+	}
+	msg := p[hs : hs+sz]
+	if big.Uint32(p[hs+sz:]) != frameSum(msg) {
+		return nil, FrameError{Offset: off, Reason: "checksum mismatch"}
+	}
+	return msg, nil
+}
+
+// Get reads record 'n' into 'dst'.
+func (l *RecordLog) Get(n int, dst Unmarshaler) error {
+	msg, err := l.ReadRaw(n)
+	if err != nil {
+		return err
+	}
+	_, err = dst.UnmarshalMsg(msg)
+	return err
+}
+
+// Range calls 'fn' with each record, starting at
+// record 'from', until 'fn' returns an error, which
+// Range then returns. The message passed to 'fn'
+// is only valid during the call.
+func (l *RecordLog) Range(from int, fn func(n int, msg []byte) error) error {
+	if from < 0 || from > len(l.index) {
+		return fmt.Errorf("msgp: record %d is out of range [0, %d]", from, len(l.index))
+	}
+	if from == len(l.index) {
+		return nil
+	}
+	var fr *FrameReader
+	for n := from; n < len(l.index); n++ {
+		if fr == nil || fr.Offset() != l.index[n] {
+			// start reading, or skip a corrupt record
+			start := l.index[n]
+			fr = NewFrameReader(io.NewSectionReader(l.file, start, l.end-start), FrameOptions{
+				Checksum: true,
+				MaxSize:  recordLogOptions.MaxSize,
+			})
+			fr.off = start
+		}
+		msg, err := fr.Next()
+		if err == nil {
+			err = fn(n, msg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compact rewrites the log, keeping only the
+// records for which 'keep' returns true. The
+// kept records are renumbered from zero.
+// The log is replaced atomically, by writing
+// a new file and renaming it over the old one,
+// and the rename is synced before Compact returns.
+func (l *RecordLog) Compact(keep func(n int, msg []byte) bool) error {
+	tmp, err := os.OpenFile(l.path+".compact", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	fw := NewFrameWriter(tmp, recordLogOptions)
+	err = fw.start()
+	if err == nil {
+		err = l.Range(0, func(n int, msg []byte) error {
+			if keep(n, msg) {
+				return fw.WriteFrame(msg)
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = fw.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	syncDir(filepath.Dir(l.path))
+	l.file.Close()
+	l.file, err = os.OpenFile(l.path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	return l.recover()
+}
+
+// syncDir makes renames in 'dir' durable. Errors
+// are ignored, since not every platform can
+// sync a directory.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Sync commits the log to stable storage.
+func (l *RecordLog) Sync() error { return l.file.Sync() }
+
+// Close closes the log.
+func (l *RecordLog) Close() error { return l.file.Close() }
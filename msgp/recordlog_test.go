@@ -0,0 +1,246 @@
+package msgp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openLog(t *testing.T, path string) *RecordLog {
+	l, err := OpenRecordLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func appendInts(t *testing.T, l *RecordLog, vals ...int64) {
+	for _, v := range vals {
+		var n Number
+		n.AsInt(v)
+		if _, err := l.Append(&n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkLog(t *testing.T, l *RecordLog, want ...int64) {
+	if l.Len() != len(want) {
+		t.Fatalf("Len() = %d; want %d", l.Len(), len(want))
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		var n Number
+		if err := l.Get(i, &n); err != nil {
+			t.Fatalf("Get(%d): %s", i, err)
+		}
+		if v, _ := n.Int(); v != want[i] {
+			t.Errorf("Get(%d) = %d; want %d", i, v, want[i])
+		}
+	}
+	seen := 0
+	err := l.Range(1, func(i int, msg []byte) error {
+		v, _, err := ReadInt64Bytes(msg)
+		if v != want[i] {
+			t.Errorf("Range: record %d = %d; want %d", i, v, want[i])
+		}
+		seen++
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen != len(want)-1 {
+		t.Errorf("Range visited %d records; want %d", seen, len(want)-1)
+	}
+}
+
+func TestRecordLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msgp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+
+	l := openLog(t, path)
+	appendInts(t, l, 1, 2, 3)
+	checkLog(t, l, 1, 2, 3)
+	l.Close()
+
+	l = openLog(t, path)
+	checkLog(t, l, 1, 2, 3)
+	appendInts(t, l, 4)
+	checkLog(t, l, 1, 2, 3, 4)
+	if l.Discarded() != 0 {
+		t.Errorf("Discarded() = %d", l.Discarded())
+	}
+
+	// compaction
+	err = l.Compact(func(n int, msg []byte) bool { return n%2 == 1 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLog(t, l, 2, 4)
+	appendInts(t, l, 5)
+	l.Close()
+	l = openLog(t, path)
+	checkLog(t, l, 2, 4, 5)
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("temporary file was left behind: %v", err)
+	}
+	if err := l.Get(3, &Number{}); err == nil {
+		t.Error("expected an error for a record out of range")
+	}
+	l.Close()
+}
+
+func TestRecordLogRecover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msgp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+
+	l := openLog(t, path)
+	appendInts(t, l, 1, 2, 3)
+	l.Close()
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := stat.Size()
+
+	// a torn write at the end
+	os.Truncate(path, size-2)
+	l = openLog(t, path)
+	checkLog(t, l, 1, 2)
+	if l.Discarded() == 0 {
+		t.Error("expected Discarded() > 0")
+	}
+	appendInts(t, l, 3)
+	l.Close()
+
+	// a corrupt last record
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0xff}, size-5)
+	f.Close()
+	l = openLog(t, path)
+	checkLog(t, l, 1, 2)
+	l.Close()
+
+	// a partial frame header at the end
+	l = openLog(t, path)
+	appendInts(t, l, 3)
+	l.Close()
+	f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0xc1, 0x7e, 0, 0})
+	f.Close()
+	l = openLog(t, path)
+	checkLog(t, l, 1, 2, 3)
+	if l.Discarded() != 4 {
+		t.Errorf("Discarded() = %d; want 4", l.Discarded())
+	}
+	l.Close()
+
+	// a partial header
+	os.Truncate(path, 3)
+	l = openLog(t, path)
+	if l.Len() != 0 {
+		t.Errorf("Len() = %d", l.Len())
+	}
+	appendInts(t, l, 7)
+	checkLog(t, l, 7)
+	l.Close()
+
+	// not a record log
+	ioutil.WriteFile(path, []byte("some other file"), 0644)
+	if _, err := OpenRecordLog(path); err == nil {
+		t.Error("expected an error opening a file that isn't a record log")
+	}
+	ioutil.WriteFile(path, []byte("MS"), 0644)
+	if l, err := OpenRecordLog(path); err != nil {
+		t.Errorf("partial header: %s", err)
+	} else {
+		l.Close()
+	}
+	ioutil.WriteFile(path, []byte("XY"), 0644)
+	if _, err := OpenRecordLog(path); err == nil {
+		t.Error("expected an error opening a short file that isn't a record log")
+	}
+}
+
+func TestRecordLogCorruptMiddle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msgp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+
+	l := openLog(t, path)
+	appendInts(t, l, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	l.Close()
+	frame := int64(recordLogOptions.frameSize(1))
+	offset := func(n int64) int64 { return recordLogStart + n*frame }
+
+	// a corrupt message in record 3, and
+	// a corrupt length in record 6
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0x7f}, offset(3)+int64(recordLogOptions.headerSize()))
+	f.WriteAt([]byte{0x01}, offset(6)+2)
+	f.Close()
+
+	l = openLog(t, path)
+	checkLog(t, l, 0, 1, 2, 4, 5, 7, 8, 9)
+	if l.Discarded() != 2*frame {
+		t.Errorf("Discarded() = %d; want %d", l.Discarded(), 2*frame)
+	}
+	appendInts(t, l, 10)
+	l.Close()
+
+	l = openLog(t, path)
+	checkLog(t, l, 0, 1, 2, 4, 5, 7, 8, 9, 10)
+	if err := l.Compact(func(int, []byte) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	checkLog(t, l, 0, 1, 2, 4, 5, 7, 8, 9, 10)
+	if l.Discarded() != 0 {
+		t.Errorf("Discarded() after Compact = %d", l.Discarded())
+	}
+	l.Close()
+	if stat, err := os.Stat(path); err != nil || stat.Size() != offset(9) {
+		t.Errorf("size after Compact = %d; want %d", stat.Size(), offset(9))
+	}
+}
+
+func TestRecordLogMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msgp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(max uint32) { recordLogOptions.MaxSize = max }(recordLogOptions.MaxSize)
+	recordLogOptions.MaxSize = 16
+
+	l := openLog(t, filepath.Join(dir, "log"))
+	defer l.Close()
+	if _, err := l.AppendRaw(AppendString(nil, "a string that is too long")); err == nil {
+		t.Error("expected an error appending a large record")
+	}
+	appendInts(t, l, 1)
+	checkLog(t, l, 1)
+}
@@ -0,0 +1,338 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// call is an outstanding request
+type call struct {
+	result msgp.Decodable
+	done   chan error
+}
+
+// peer is one end of a connection. It reads
+// requests and notifications for its Server, if
+// any, and responses to the calls it has made.
+type peer struct {
+	r      *msgp.Reader
+	w      wire
+	srv    *Server
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // running handlers
+
+	mu      sync.Mutex
+	seq     uint32
+	pending map[uint32]*call
+	err     error // set when the connection is shut down
+}
+
+func newPeer(ctx context.Context, conn io.ReadWriteCloser, s *Server) *peer {
+	ctx, cancel := context.WithCancel(ctx)
+	return &peer{
+		r:       msgp.NewReader(conn),
+		w:       wire{conn: conn},
+		srv:     s,
+		ctx:     ctx,
+		cancel:  cancel,
+		pending: make(map[uint32]*call),
+	}
+}
+
+// loop reads messages until an error
+func (p *peer) loop() error {
+	for {
+		sz, err := p.r.ReadArrayHeader()
+		if err != nil {
+			return err
+		}
+		typ, err := p.r.ReadInt()
+		if err != nil {
+			return err
+		}
+		switch {
+		case typ == msgRequest && sz == 4:
+			err = p.request()
+		case typ == msgResponse && sz == 4:
+			err = p.response()
+		case typ == msgNotification && sz == 3:
+			err = p.notification()
+		default:
+			err = fmt.Errorf("rpc: invalid message of type %d with %d elements", typ, sz)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// shutdown fails all of the outstanding calls and
+// then waits for running handlers, which may be
+// blocked on calls or writes of their own
+func (p *peer) shutdown(err error) {
+	p.cancel()
+	p.w.conn.Close()
+	if err == io.EOF {
+		err = ErrShutdown
+	}
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	for id, c := range p.pending {
+		delete(p.pending, id)
+		c.done <- p.err
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// readCall reads the method and params of a request or notification
+func (p *peer) readCall() (string, msgp.Raw, error) {
+	name, err := p.r.ReadString()
+	if err != nil {
+		return "", nil, err
+	}
+	var params msgp.Raw
+	err = params.DecodeMsg(p.r)
+	return name, params, err
+}
+
+func (p *peer) request() error {
+	id, err := p.r.ReadUint32()
+	if err != nil {
+		return err
+	}
+	name, params, err := p.readCall()
+	if err != nil {
+		return err
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		res, err := p.srv.call(p.ctx, name, params)
+		msg, eerr := encodeResponse(id, res, err)
+		if eerr != nil {
+			msg, _ = encodeResponse(id, nil, eerr)
+		}
+		// a write error also stops the read loop
+		p.w.write(msg)
+	}()
+	return nil
+}
+
+func (p *peer) notification() error {
+	name, params, err := p.readCall()
+	if err != nil {
+		return err
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.srv.call(p.ctx, name, params)
+	}()
+	return nil
+}
+
+func (p *peer) response() error {
+	id, err := p.r.ReadUint32()
+	if err != nil {
+		return err
+	}
+	var rerr error
+	t, err := p.r.NextType()
+	if err != nil {
+		return err
+	}
+	if t == msgp.NilType {
+		err = p.r.ReadNil()
+	} else {
+		var v interface{}
+		v, err = p.r.ReadIntf()
+		rerr = &ServerError{Value: v}
+	}
+	if err != nil {
+		return err
+	}
+	var result msgp.Raw
+	if err := result.DecodeMsg(p.r); err != nil {
+		return err
+	}
+
+	c := p.take(id)
+	if c == nil {
+		// the call was canceled
+		return nil
+	}
+	if rerr == nil && c.result != nil {
+		rerr = msgp.Decode(bytes.NewReader(result), c.result)
+	}
+	c.done <- rerr
+	return nil
+}
+
+// take removes and returns the outstanding
+// call 'id', or nil if there isn't one
+func (p *peer) take(id uint32) *call {
+	p.mu.Lock()
+	c := p.pending[id]
+	delete(p.pending, id)
+	p.mu.Unlock()
+	return c
+}
+
+func encodeResponse(id uint32, res msgp.Encodable, herr error) ([]byte, error) {
+	return encode(func(w *msgp.Writer) error {
+		if err := w.WriteArrayHeader(4); err != nil {
+			return err
+		}
+		if err := w.WriteInt(msgResponse); err != nil {
+			return err
+		}
+		if err := w.WriteUint32(id); err != nil {
+			return err
+		}
+		if herr != nil {
+			if e, ok := herr.(msgp.Encodable); ok {
+				if err := e.EncodeMsg(w); err != nil {
+					return err
+				}
+			} else if err := w.WriteString(herr.Error()); err != nil {
+				return err
+			}
+			return w.WriteNil()
+		}
+		if err := w.WriteNil(); err != nil {
+			return err
+		}
+		return writeOptional(w, res)
+	})
+}
+
+// Client makes calls over a connection.
+// Its methods may be called concurrently.
+type Client struct {
+	p *peer
+}
+
+// NewClient returns a Client that makes calls over 'conn'.
+// If 's' is non-nil, requests and notifications sent by
+// the other end of the connection are dispatched to it.
+func NewClient(conn io.ReadWriteCloser, s *Server) *Client {
+	p := newPeer(context.Background(), conn, s)
+	go func() {
+		p.shutdown(p.loop())
+	}()
+	return &Client{p: p}
+}
+
+// Call calls 'method' with 'params', which should encode
+// as an array, and decodes the result into 'result'. Either
+// may be nil. If 'ctx' is done before the response arrives,
+// Call returns ctx.Err(), and the response is discarded;
+// MessagePack-RPC has no way to cancel the request itself.
+func (c *Client) Call(ctx context.Context, method string, params msgp.Encodable, result msgp.Decodable) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p := c.p
+	cl := &call{result: result, done: make(chan error, 1)}
+	p.mu.Lock()
+	if p.err != nil {
+		p.mu.Unlock()
+		return p.err
+	}
+	p.seq++
+	id := p.seq
+	p.pending[id] = cl
+	p.mu.Unlock()
+
+	msg, err := encode(func(w *msgp.Writer) error {
+		if err := w.WriteArrayHeader(4); err != nil {
+			return err
+		}
+		if err := w.WriteInt(msgRequest); err != nil {
+			return err
+		}
+		if err := w.WriteUint32(id); err != nil {
+			return err
+		}
+		if err := w.WriteString(method); err != nil {
+			return err
+		}
+		return writeParams(w, params)
+	})
+	if err == nil {
+		err = p.w.write(msg)
+	}
+	if err != nil {
+		p.take(id)
+		return err
+	}
+
+	select {
+	case err := <-cl.done:
+		return err
+	case <-ctx.Done():
+		if p.take(id) != nil {
+			return ctx.Err()
+		}
+		// the response is already being read
+		return <-cl.done
+	}
+}
+
+// Notify sends a notification of 'method' with
+// 'params', which should encode as an array.
+func (c *Client) Notify(method string, params msgp.Encodable) error {
+	p := c.p
+	p.mu.Lock()
+	err := p.err
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	msg, err := encode(func(w *msgp.Writer) error {
+		if err := w.WriteArrayHeader(3); err != nil {
+			return err
+		}
+		if err := w.WriteInt(msgNotification); err != nil {
+			return err
+		}
+		if err := w.WriteString(method); err != nil {
+			return err
+		}
+		return writeParams(w, params)
+	})
+	if err != nil {
+		return err
+	}
+	return p.w.write(msg)
+}
+
+// Close closes the connection. Outstanding
+// calls return ErrShutdown.
+func (c *Client) Close() error {
+	p := c.p
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = ErrShutdown
+	}
+	p.mu.Unlock()
+	return p.w.conn.Close()
+}
+
+// writeParams writes 'params', or an
+// empty array if it is nil
+func writeParams(w *msgp.Writer, params msgp.Encodable) error {
+	if params == nil {
+		return w.WriteArrayHeader(0)
+	}
+	return params.EncodeMsg(w)
+}
@@ -0,0 +1,221 @@
+package rpc
+
+import (
+	"fmt"
+	"io"
+	netrpc "net/rpc"
+	"reflect"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// The codecs for net/rpc write each request as
+// [ServiceMethod, Seq] followed by the args, and
+// each response as [ServiceMethod, Seq, Error]
+// followed by the reply. Args and replies that
+// implement msgp.Encodable and msgp.Decodable use
+// those methods; other values are written with
+// (*msgp.Writer).WriteIntf and read with
+// (*msgp.Reader).ReadIntf.
+
+type serverCodec struct {
+	conn io.ReadWriteCloser
+	r    *msgp.Reader
+	w    *msgp.Writer
+}
+
+// NewServerCodec returns a net/rpc ServerCodec
+// that reads and writes MessagePack on 'conn', e.g.
+//
+//	go rpc.ServeCodec(msgprpc.NewServerCodec(conn))
+//
+func NewServerCodec(conn io.ReadWriteCloser) netrpc.ServerCodec {
+	return &serverCodec{conn: conn, r: msgp.NewReader(conn), w: msgp.NewWriter(conn)}
+}
+
+func (c *serverCodec) ReadRequestHeader(req *netrpc.Request) error {
+	if err := readHeader(c.r, 2); err != nil {
+		return err
+	}
+	var err error
+	req.ServiceMethod, err = c.r.ReadString()
+	if err != nil {
+		return err
+	}
+	req.Seq, err = c.r.ReadUint64()
+	return err
+}
+
+func (c *serverCodec) ReadRequestBody(x interface{}) error {
+	return readBody(c.r, x)
+}
+
+func (c *serverCodec) WriteResponse(resp *netrpc.Response, x interface{}) error {
+	err := c.w.WriteArrayHeader(3)
+	if err == nil {
+		err = c.w.WriteString(resp.ServiceMethod)
+	}
+	if err == nil {
+		err = c.w.WriteUint64(resp.Seq)
+	}
+	if err == nil {
+		err = c.w.WriteString(resp.Error)
+	}
+	if err == nil {
+		// the body of an error response is a placeholder
+		if resp.Error != "" {
+			x = nil
+		}
+		err = c.w.WriteIntf(x)
+	}
+	if err == nil {
+		err = c.w.Flush()
+	}
+	if err != nil {
+		// the stream may hold a partial message
+		c.Close()
+	}
+	return err
+}
+
+func (c *serverCodec) Close() error { return c.conn.Close() }
+
+type clientCodec struct {
+	conn io.ReadWriteCloser
+	r    *msgp.Reader
+	w    *msgp.Writer
+}
+
+// NewClientCodec returns a net/rpc ClientCodec
+// that reads and writes MessagePack on 'conn', e.g.
+//
+//	client := rpc.NewClientWithCodec(msgprpc.NewClientCodec(conn))
+//
+func NewClientCodec(conn io.ReadWriteCloser) netrpc.ClientCodec {
+	return &clientCodec{conn: conn, r: msgp.NewReader(conn), w: msgp.NewWriter(conn)}
+}
+
+func (c *clientCodec) WriteRequest(req *netrpc.Request, x interface{}) error {
+	err := c.w.WriteArrayHeader(2)
+	if err == nil {
+		err = c.w.WriteString(req.ServiceMethod)
+	}
+	if err == nil {
+		err = c.w.WriteUint64(req.Seq)
+	}
+	if err == nil {
+		err = c.w.WriteIntf(x)
+	}
+	if err == nil {
+		err = c.w.Flush()
+	}
+	if err != nil {
+		// the stream may hold a partial message
+		c.Close()
+	}
+	return err
+}
+
+func (c *clientCodec) ReadResponseHeader(resp *netrpc.Response) error {
+	if err := readHeader(c.r, 3); err != nil {
+		return err
+	}
+	var err error
+	resp.ServiceMethod, err = c.r.ReadString()
+	if err != nil {
+		return err
+	}
+	resp.Seq, err = c.r.ReadUint64()
+	if err != nil {
+		return err
+	}
+	resp.Error, err = c.r.ReadString()
+	return err
+}
+
+func (c *clientCodec) ReadResponseBody(x interface{}) error {
+	return readBody(c.r, x)
+}
+
+func (c *clientCodec) Close() error { return c.conn.Close() }
+
+// readHeader reads an array header of size 'sz'
+func readHeader(r *msgp.Reader, sz uint32) error {
+	n, err := r.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	if n != sz {
+		return msgp.ArrayError{Wanted: sz, Got: n}
+	}
+	return nil
+}
+
+// readBody reads an object into 'x', which
+// is a pointer, or skips it if 'x' is nil
+func readBody(r *msgp.Reader, x interface{}) error {
+	if x == nil {
+		return r.Skip()
+	}
+	if d, ok := x.(msgp.Decodable); ok {
+		return d.DecodeMsg(r)
+	}
+	v, err := r.ReadIntf()
+	if err != nil {
+		return err
+	}
+	return assign(x, v)
+}
+
+// assign stores 'v', as returned by ReadIntf, in
+// the pointer 'x', converting between numeric
+// types when the value is preserved
+func assign(x interface{}, v interface{}) error {
+	dst := reflect.ValueOf(x)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return fmt.Errorf("msgp/rpc: can't decode into non-pointer %T", x)
+	}
+	dst = dst.Elem()
+	if v == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	src := reflect.ValueOf(v)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+	if (isNumber(src.Kind()) && isNumber(dst.Kind())) ||
+		(src.Kind() == reflect.String && dst.Kind() == reflect.String) {
+		// the conversion must round-trip without
+		// wrapping between signed and unsigned
+		cv := src.Convert(dst.Type())
+		if cv.Convert(src.Type()).Interface() == v && isNegative(cv) == isNegative(src) {
+			dst.Set(cv)
+			return nil
+		}
+	}
+	return fmt.Errorf("msgp/rpc: can't decode %T into %T", v, x)
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isNegative(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() < 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() < 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"errors"
+	"math"
+	"net"
+	netrpc "net/rpc"
+	"testing"
+)
+
+type Arith struct{}
+
+// Add uses generated-style methods for its
+// args, and the reflection path for its reply
+func (a *Arith) Add(p *AddParams, sum *int) error {
+	*sum = p.A + p.B
+	return nil
+}
+
+// Swap uses generated-style methods for both
+func (a *Arith) Swap(p *AddParams, out *AddParams) error {
+	out.A, out.B = p.B, p.A
+	return nil
+}
+
+// Half uses the reflection path for both
+func (a *Arith) Half(n float64, out *float64) error {
+	if n < 0 {
+		return errors.New("negative")
+	}
+	*out = n / 2
+	return nil
+}
+
+func TestNetRPC(t *testing.T) {
+	srv := netrpc.NewServer()
+	if err := srv.Register(new(Arith)); err != nil {
+		t.Fatal(err)
+	}
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewServerCodec(sconn))
+	c := netrpc.NewClientWithCodec(NewClientCodec(cconn))
+	defer c.Close()
+
+	var sum int
+	if err := c.Call("Arith.Add", &AddParams{2, 40}, &sum); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 42 {
+		t.Errorf("Add: got %d", sum)
+	}
+
+	var out AddParams
+	if err := c.Call("Arith.Swap", &AddParams{1, 2}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != (AddParams{2, 1}) {
+		t.Errorf("Swap: got %+v", out)
+	}
+
+	var half float64
+	if err := c.Call("Arith.Half", 3.0, &half); err != nil {
+		t.Fatal(err)
+	}
+	if half != 1.5 {
+		t.Errorf("Half: got %v", half)
+	}
+	err := c.Call("Arith.Half", -1.0, &half)
+	if _, ok := err.(netrpc.ServerError); !ok || err.Error() != "negative" {
+		t.Errorf("Half(-1): got error %v", err)
+	}
+
+	// the connection survives errors
+	if err := c.Call("Arith.Missing", 1, &half); err == nil {
+		t.Error("expected an error calling a missing method")
+	}
+	if err := c.Call("Arith.Add", &AddParams{1, 1}, &sum); err != nil || sum != 2 {
+		t.Errorf("Add after errors: %d, %v", sum, err)
+	}
+}
+
+func TestAssign(t *testing.T) {
+	var i8 int8
+	if err := assign(&i8, int64(100)); err != nil || i8 != 100 {
+		t.Errorf("assign int64 to int8: %d, %v", i8, err)
+	}
+	if err := assign(&i8, int64(300)); err == nil {
+		t.Error("expected an error assigning 300 to an int8")
+	}
+	var u64 uint64
+	if err := assign(&u64, int64(-1)); err == nil {
+		t.Errorf("expected an error assigning -1 to a uint64; got %d", u64)
+	}
+	var u uint
+	if err := assign(&u, int64(-1)); err == nil {
+		t.Errorf("expected an error assigning -1 to a uint; got %d", u)
+	}
+	var i64 int64
+	if err := assign(&i64, uint64(math.MaxUint64)); err == nil {
+		t.Errorf("expected an error assigning MaxUint64 to an int64; got %d", i64)
+	}
+	if err := assign(&u64, int64(7)); err != nil || u64 != 7 {
+		t.Errorf("assign int64 to uint64: %d, %v", u64, err)
+	}
+	var s string
+	if err := assign(&s, int64(65)); err == nil {
+		t.Error("expected an error assigning an int to a string")
+	}
+	if err := assign(s, "x"); err == nil {
+		t.Error("expected an error assigning to a non-pointer")
+	}
+}
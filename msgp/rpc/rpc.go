@@ -0,0 +1,121 @@
+// Package rpc implements MessagePack-RPC
+// (https://github.com/msgpack-rpc/msgpack-rpc/blob/master/spec.md)
+// on top of the msgp Reader and Writer types.
+//
+// A Server dispatches requests and notifications to handlers
+// registered by method name, and a Client makes concurrent calls
+// over a single connection. A Client can also be given a Server,
+// so that it responds to requests and notifications sent by the
+// other end of the connection, as Neovim does.
+//
+// Params are always encoded as an array. Types generated with
+// the //msgp:tuple directive, and the Args type, encode that way.
+//
+// NewServerCodec and NewClientCodec run the standard net/rpc
+// package over MessagePack instead; that protocol is specific
+// to net/rpc, and isn't MessagePack-RPC.
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// message types
+const (
+	msgRequest      = 0 // [0, msgid, method, params]
+	msgResponse     = 1 // [1, msgid, error, result]
+	msgNotification = 2 // [2, method, params]
+)
+
+// ErrShutdown is returned by calls on
+// a connection that has been closed.
+var ErrShutdown = errors.New("rpc: connection is shut down")
+
+// ServerError is an error returned
+// by the other end of a connection.
+type ServerError struct {
+	Value interface{} // the error object, as read by (*msgp.Reader).ReadIntf
+}
+
+// Error implements the error interface
+func (e *ServerError) Error() string {
+	if s, ok := e.Value.(string); ok {
+		return "rpc: " + s
+	}
+	return fmt.Sprintf("rpc: %v", e.Value)
+}
+
+// Args is an array of values of any of the
+// types supported by (*msgp.Writer).WriteIntf.
+// It can be used as the params or result of
+// a call when there is no generated type for them.
+type Args []interface{}
+
+// EncodeMsg implements msgp.Encodable
+func (a Args) EncodeMsg(w *msgp.Writer) error {
+	if err := w.WriteArrayHeader(uint32(len(a))); err != nil {
+		return err
+	}
+	for _, v := range a {
+		if err := w.WriteIntf(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMsg implements msgp.Decodable
+func (a *Args) DecodeMsg(r *msgp.Reader) error {
+	sz, err := r.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	*a = make(Args, sz)
+	for i := range *a {
+		(*a)[i], err = r.ReadIntf()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode returns the messagepack written by 'fn'
+func encode(fn func(w *msgp.Writer) error) ([]byte, error) {
+	var buf bytes.Buffer
+	w := msgp.NewWriter(&buf)
+	if err := fn(w); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeOptional writes 'e', or nil if 'e' is nil
+func writeOptional(w *msgp.Writer, e msgp.Encodable) error {
+	if e == nil {
+		return w.WriteNil()
+	}
+	return e.EncodeMsg(w)
+}
+
+// wire serializes writes of whole messages to a connection
+type wire struct {
+	mu   sync.Mutex
+	conn io.ReadWriteCloser
+}
+
+func (w *wire) write(msg []byte) error {
+	w.mu.Lock()
+	_, err := w.conn.Write(msg)
+	w.mu.Unlock()
+	return err
+}
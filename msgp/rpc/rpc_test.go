@@ -0,0 +1,227 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// AddParams is a tuple of two ints
+type AddParams struct {
+	A, B int
+}
+
+func (a *AddParams) DecodeMsg(r *msgp.Reader) error {
+	sz, err := r.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	if sz != 2 {
+		return msgp.ArrayError{Wanted: 2, Got: sz}
+	}
+	if a.A, err = r.ReadInt(); err != nil {
+		return err
+	}
+	a.B, err = r.ReadInt()
+	return err
+}
+
+func (a *AddParams) EncodeMsg(w *msgp.Writer) error {
+	if err := w.WriteArrayHeader(2); err != nil {
+		return err
+	}
+	if err := w.WriteInt(a.A); err != nil {
+		return err
+	}
+	return w.WriteInt(a.B)
+}
+
+// testServer returns a Server with the methods "add",
+// "fail", "block" and "note", and a channel that
+// receives the params of each "note" notification
+func testServer() (*Server, chan Args) {
+	notes := make(chan Args, 1)
+	s := NewServer()
+	s.Register("add", func() msgp.Decodable { return new(AddParams) },
+		func(ctx context.Context, params msgp.Decodable) (msgp.Encodable, error) {
+			p := params.(*AddParams)
+			return Args{p.A + p.B}, nil
+		})
+	s.Register("fail", nil, func(ctx context.Context, params msgp.Decodable) (msgp.Encodable, error) {
+		return nil, errors.New("failed")
+	})
+	s.Register("block", nil, func(ctx context.Context, params msgp.Decodable) (msgp.Encodable, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	s.Register("note", func() msgp.Decodable { return new(Args) },
+		func(ctx context.Context, params msgp.Decodable) (msgp.Encodable, error) {
+			notes <- *params.(*Args)
+			return nil, nil
+		})
+	return s, notes
+}
+
+func pipe(s *Server) (*Client, chan error) {
+	cconn, sconn := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- s.ServeConn(context.Background(), sconn) }()
+	return NewClient(cconn, nil), done
+}
+
+func TestCall(t *testing.T) {
+	s, notes := testServer()
+	c, done := pipe(s)
+
+	// concurrent calls are multiplexed
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var res Args
+			if err := c.Call(context.Background(), "add", &AddParams{i, 1}, &res); err != nil {
+				t.Error(err)
+				return
+			}
+			if len(res) != 1 || res[0] != int64(i+1) {
+				t.Errorf("add(%d, 1) = %v", i, res)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	err := c.Call(context.Background(), "fail", nil, nil)
+	if se, ok := err.(*ServerError); !ok || se.Value != "failed" {
+		t.Errorf("fail: got error %v", err)
+	}
+	err = c.Call(context.Background(), "missing", nil, nil)
+	if _, ok := err.(*ServerError); !ok {
+		t.Errorf("missing: got error %v", err)
+	}
+	err = c.Call(context.Background(), "add", Args{"x"}, nil)
+	if _, ok := err.(*ServerError); !ok {
+		t.Errorf("add with bad params: got error %v", err)
+	}
+
+	if err := c.Notify("note", Args{"hello", 3}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case args := <-notes:
+		if len(args) != 2 || args[0] != "hello" || args[1] != int64(3) {
+			t.Errorf("notification params: %v", args)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("ServeConn returned %v", err)
+	}
+	if err := c.Call(context.Background(), "add", &AddParams{1, 2}, nil); err != ErrShutdown {
+		t.Errorf("call after Close: got error %v; want ErrShutdown", err)
+	}
+}
+
+func TestCallCancel(t *testing.T) {
+	s, _ := testServer()
+	c, done := pipe(s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Call(ctx, "block", nil, nil); err != context.DeadlineExceeded {
+		t.Errorf("got error %v; want context.DeadlineExceeded", err)
+	}
+
+	// the connection is still usable
+	var res Args
+	if err := c.Call(context.Background(), "add", &AddParams{2, 3}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res[0] != int64(5) {
+		t.Errorf("add(2, 3) = %v", res)
+	}
+
+	// closing the connection cancels the blocked handler
+	c.Close()
+	if err := <-done; err != nil {
+		t.Errorf("ServeConn returned %v", err)
+	}
+}
+
+func TestClientServer(t *testing.T) {
+	// each end of the connection can call the other
+	a, b := net.Pipe()
+	sa, _ := testServer()
+	sb, notes := testServer()
+	ca := NewClient(a, sa)
+	cb := NewClient(b, sb)
+	defer ca.Close()
+	defer cb.Close()
+
+	var res Args
+	if err := ca.Call(context.Background(), "add", &AddParams{1, 1}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Call(context.Background(), "add", &AddParams{2, 2}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res[0] != int64(4) {
+		t.Errorf("add(2, 2) = %v", res)
+	}
+	if err := ca.Notify("note", Args{true}); err != nil {
+		t.Fatal(err)
+	}
+	if args := <-notes; len(args) != 1 || args[0] != true {
+		t.Errorf("notification params: %v", args)
+	}
+}
+
+func TestShutdownWithCallback(t *testing.T) {
+	// a handler that calls back to the other end
+	// must not hold up shutdown when the
+	// connection is lost
+	a, b := net.Pipe()
+	var ca *Client
+	result := make(chan error, 1)
+	s := NewServer()
+	s.Register("callback", nil, func(ctx context.Context, params msgp.Decodable) (msgp.Encodable, error) {
+		err := ca.Call(context.Background(), "never", nil, nil)
+		result <- err
+		return nil, err
+	})
+	ca = NewClient(a, s)
+
+	w := msgp.NewWriter(b)
+	w.WriteArrayHeader(4)
+	w.WriteInt(msgRequest)
+	w.WriteUint32(1)
+	w.WriteString("callback")
+	w.WriteArrayHeader(0)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// read the callback, but never answer it
+	if err := msgp.NewReader(b).Skip(); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Error("expected an error from the callback")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown deadlocked")
+	}
+}
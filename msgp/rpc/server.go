@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// HandlerFunc handles a request or notification. 'params'
+// is the value returned by the method's params function,
+// decoded from the request's params, or nil if there is no
+// params function. The result is ignored for notifications.
+//
+// If the returned error implements msgp.Encodable, it is
+// sent as the error object; otherwise, its message is sent
+// as a string.
+type HandlerFunc func(ctx context.Context, params msgp.Decodable) (msgp.Encodable, error)
+
+type method struct {
+	params func() msgp.Decodable
+	fn     HandlerFunc
+}
+
+// Server dispatches requests and notifications
+// to the handlers registered for their method.
+type Server struct {
+	mu      sync.RWMutex
+	methods map[string]method
+}
+
+// NewServer returns a new Server
+// with no registered methods.
+func NewServer() *Server {
+	return &Server{methods: make(map[string]method)}
+}
+
+// Register registers 'fn' as the handler for 'name'.
+// 'params' returns a new value to decode the params of
+// each request into, e.g.
+//
+//	func() msgp.Decodable { return new(AddParams) }
+//
+// If 'params' is nil, the params are skipped.
+// Register panics if 'name' is already registered.
+func (s *Server) Register(name string, params func() msgp.Decodable, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.methods[name]; ok {
+		panic("rpc: method " + name + " registered twice")
+	}
+	s.methods[name] = method{params: params, fn: fn}
+}
+
+func (s *Server) lookup(name string) (method, bool) {
+	if s == nil {
+		return method{}, false
+	}
+	s.mu.RLock()
+	m, ok := s.methods[name]
+	s.mu.RUnlock()
+	return m, ok
+}
+
+// call decodes 'params' and calls the handler
+// for 'name', which may not be registered
+func (s *Server) call(ctx context.Context, name string, params msgp.Raw) (msgp.Encodable, error) {
+	m, ok := s.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("method not found: %s", name)
+	}
+	var p msgp.Decodable
+	if m.params != nil {
+		p = m.params()
+		if err := msgp.Decode(bytes.NewReader(params), p); err != nil {
+			return nil, fmt.Errorf("invalid params for %s: %s", name, err)
+		}
+	}
+	return m.fn(ctx, p)
+}
+
+// ServeConn serves requests and notifications read from
+// 'conn' until it is closed or returns an error, and then
+// closes it. When the connection ends, the context passed
+// to handlers is canceled while they are still running, so
+// that they can stop early; ServeConn then waits for all of
+// the handlers to return before returning itself.
+func (s *Server) ServeConn(ctx context.Context, conn io.ReadWriteCloser) error {
+	p := newPeer(ctx, conn, s)
+	err := p.loop()
+	p.shutdown(err)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// Serve accepts connections from 'l' and serves
+// each of them with ServeConn, until Accept
+// returns an error.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(ctx, conn)
+	}
+}
@@ -25,9 +25,14 @@ const (
 	Complex64Size  = 10
 	Complex128Size = 18
 
-	TimeSize = 15
-	BoolSize = 1
-	NilSize  = 1
+	TimeSize     = 15
+	DurationSize = Int64Size
+	BoolSize     = 1
+	NilSize      = 1
+
+	// IPSize is the size of the
+	// largest (IPv6) net.IP
+	IPSize = 18
 
 	MapHeaderSize   = 5
 	ArrayHeaderSize = 5
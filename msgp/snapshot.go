@@ -0,0 +1,153 @@
+package msgp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// A snapshot file is a framed stream (see FrameOptions)
+// with a magic/version header, holding a single
+// checksummed frame.
+
+func snapshotOptions(magic []byte, version uint8) (FrameOptions, error) {
+	if len(magic) == 0 {
+		return FrameOptions{}, fmt.Errorf("msgp: snapshot magic must not be empty")
+	}
+	return FrameOptions{
+		Checksum: true,
+		Magic:    magic,
+		Version:  version,
+	}, nil
+}
+
+// SaveSnapshot atomically replaces the file at 'path'
+// with 'src', preceded by 'magic' and 'version', and
+// followed by a checksum. The snapshot is written to
+// a temporary file in the same directory, which is
+// synced and then renamed over 'path', so a crash
+// leaves either the old or the new snapshot.
+func SaveSnapshot(path string, src MarshalSizer, magic []byte, version uint8) error {
+	opts, err := snapshotOptions(magic, version)
+	if err != nil {
+		return err
+	}
+	msg, err := src.MarshalMsg(make([]byte, 0, src.Msgsize()))
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(path); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := ioutil.TempFile(dir, name+".tmp")
+	if err != nil {
+		return err
+	}
+	fw := NewFrameWriter(tmp, opts)
+	err = fw.WriteFrame(msg)
+	if err == nil {
+		err = fw.Flush()
+	}
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// openSnapshot reads the header of the snapshot at 'path'
+func openSnapshot(path string, magic []byte) (*os.File, *FrameReader, error) {
+	opts, err := snapshotOptions(magic, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	// a corrupt length must not be able to
+	// make Next allocate more than the file holds
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	max := stat.Size() - int64(len(magic)+1+opts.frameSize(0))
+	if max > math.MaxUint32 {
+		max = math.MaxUint32
+	} else if max < 1 {
+		max = 1
+	}
+	opts.MaxSize = uint32(max)
+	fr := NewFrameReader(f, opts)
+	if _, err := fr.Version(); err != nil {
+		f.Close()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, err
+	}
+	return f, fr, nil
+}
+
+// SnapshotVersion returns the version of the
+// snapshot at 'path', which must start with 'magic'.
+// It does not read the rest of the snapshot.
+func SnapshotVersion(path string, magic []byte) (uint8, error) {
+	f, fr, err := openSnapshot(path, magic)
+	if err != nil {
+		return 0, err
+	}
+	f.Close()
+	return fr.version, nil
+}
+
+// LoadSnapshot reads the snapshot at 'path', written
+// by SaveSnapshot, into 'dst'. It returns an error if
+// the snapshot doesn't start with 'magic', if its
+// version isn't 'version', if it is truncated, or
+// if its checksum doesn't match. To support more
+// than one version, call SnapshotVersion first.
+func LoadSnapshot(path string, dst Unmarshaler, magic []byte, version uint8) error {
+	f, fr, err := openSnapshot(path, magic)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if fr.version != version {
+		return fmt.Errorf("msgp: snapshot %s has version %d; want %d", path, fr.version, version)
+	}
+	msg, err := fr.Next()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if _, err := fr.r.R.Peek(1); err != io.EOF {
+		return FrameError{Offset: fr.Offset(), Reason: "unexpected data after snapshot"}
+	}
+	_, err = dst.UnmarshalMsg(msg)
+	return err
+}
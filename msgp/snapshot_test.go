@@ -0,0 +1,79 @@
+package msgp
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msgp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state")
+	magic := []byte("TEST")
+
+	in := Raw(AppendMapStrStr(nil, map[string]string{"a": "b"}))
+	if err := SaveSnapshot(path, in, magic, 2); err != nil {
+		t.Fatal(err)
+	}
+	in = Raw(AppendMapStrStr(nil, map[string]string{"c": "d"}))
+	if err := SaveSnapshot(path, in, magic, 2); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := SnapshotVersion(path, magic); v != 2 || err != nil {
+		t.Errorf("SnapshotVersion() = %d, %v", v, err)
+	}
+	var out Raw
+	if err := LoadSnapshot(path, &out, magic, 2); err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(in) {
+		t.Errorf("loaded %x; want %x", []byte(out), []byte(in))
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 1 {
+		t.Errorf("temporary files were left behind: %v", names)
+	}
+
+	if err := LoadSnapshot(path, &out, magic, 3); err == nil {
+		t.Error("expected an error for the wrong version")
+	}
+	if err := LoadSnapshot(path, &out, []byte("XXXX"), 2); err == nil {
+		t.Error("expected an error for the wrong magic")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-6] ^= 0x01
+	ioutil.WriteFile(path, data, 0644)
+	if _, ok := LoadSnapshot(path, &out, magic, 2).(FrameError); !ok {
+		t.Error("expected a FrameError for a corrupt snapshot")
+	}
+	// a length that is too large for the file, even
+	// with a good checksum, is caught before the
+	// message is read
+	bad := append([]byte(nil), data...)
+	l := bad[len(magic)+3:]
+	big.PutUint32(l, 1<<31)
+	big.PutUint32(l[4:], frameSum(l[:4]))
+	ioutil.WriteFile(path, bad, 0644)
+	if err, ok := LoadSnapshot(path, &out, magic, 2).(FrameError); !ok || !strings.Contains(err.Reason, "larger than the maximum") {
+		t.Errorf("corrupt length: got %v", err)
+	}
+	// a truncated snapshot is shorter than its length
+	ioutil.WriteFile(path, data[:len(data)-3], 0644)
+	if err, ok := LoadSnapshot(path, &out, magic, 2).(FrameError); !ok {
+		t.Errorf("truncated snapshot: got %v", err)
+	}
+	ioutil.WriteFile(path, nil, 0644)
+	if err := LoadSnapshot(path, &out, magic, 2); err != io.ErrUnexpectedEOF {
+		t.Errorf("empty snapshot: got %v", err)
+	}
+}
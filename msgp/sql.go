@@ -0,0 +1,72 @@
+package msgp
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// SQLRaw is raw MessagePack stored in a
+// database/sql column. It implements driver.Valuer
+// and sql.Scanner, and marshals to JSON using
+// UnmarshalAsJSON, so columns of MessagePack can
+// be inspected and served without decoding them.
+// A NULL column is an empty SQLRaw.
+type SQLRaw Raw
+
+// Value implements driver.Valuer
+func (r SQLRaw) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	return []byte(r), nil
+}
+
+// Scan implements sql.Scanner. The
+// contents of 'src' must be exactly
+// one MessagePack object.
+func (r *SQLRaw) Scan(src interface{}) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+		*r = (*r)[:0]
+		return nil
+	case []byte:
+		b = src
+	case string:
+		b = []byte(src)
+	default:
+		return fmt.Errorf("msgp: can't scan %T into SQLRaw", src)
+	}
+	rest, err := (*Raw)(r).UnmarshalMsg(b)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("msgp: %d extra bytes after the object in SQLRaw", len(rest))
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+// An empty SQLRaw is null.
+func (r SQLRaw) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	return (*Raw)(&r).MarshalJSON()
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (r SQLRaw) MarshalMsg(b []byte) ([]byte, error) { return Raw(r).MarshalMsg(b) }
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (r *SQLRaw) UnmarshalMsg(b []byte) ([]byte, error) { return (*Raw)(r).UnmarshalMsg(b) }
+
+// EncodeMsg implements msgp.Encodable
+func (r SQLRaw) EncodeMsg(w *Writer) error { return Raw(r).EncodeMsg(w) }
+
+// DecodeMsg implements msgp.Decodable
+func (r *SQLRaw) DecodeMsg(f *Reader) error { return (*Raw)(r).DecodeMsg(f) }
+
+// Msgsize implements msgp.Sizer
+func (r SQLRaw) Msgsize() int { return Raw(r).Msgsize() }
@@ -0,0 +1,52 @@
+package msgp
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSQLRaw(t *testing.T) {
+	msg := AppendMapStrStr(nil, map[string]string{"a": "b"})
+
+	var r SQLRaw
+	if err := r.Scan(msg); err != nil {
+		t.Fatal(err)
+	}
+	msg[len(msg)-1] = 'c' // Scan copies
+	v, err := r.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := v.([]byte); !ok || !bytes.Equal(b, []byte(r)) || r[len(r)-1] != 'b' {
+		t.Errorf("Value() = %v", v)
+	}
+
+	js, err := json.Marshal(struct{ Doc SQLRaw }{r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(js) != `{"Doc":{"a":"b"}}` {
+		t.Errorf("json.Marshal: %s", js)
+	}
+
+	if err := r.Scan(nil); err != nil || len(r) != 0 {
+		t.Errorf("Scan(nil): %v, %v", r, err)
+	}
+	if v, err := r.Value(); v != nil || err != nil {
+		t.Errorf("Value() of NULL = %v, %v", v, err)
+	}
+	if js, _ := json.Marshal(r); string(js) != "null" {
+		t.Errorf("json.Marshal of NULL: %s", js)
+	}
+
+	if err := r.Scan(append(AppendInt(nil, 1), 0xc0)); err == nil {
+		t.Error("expected an error scanning two objects")
+	}
+	if err := r.Scan([]byte{0xc1}); err == nil {
+		t.Error("expected an error scanning an invalid object")
+	}
+	if err := r.Scan(3); err == nil {
+		t.Error("expected an error scanning an int")
+	}
+}
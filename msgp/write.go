@@ -91,6 +91,14 @@ type Marshaler interface {
 	MarshalMsg([]byte) ([]byte, error)
 }
 
+// MarshalSizer is the combination
+// of the Marshaler and Sizer
+// interfaces.
+type MarshalSizer interface {
+	Marshaler
+	Sizer
+}
+
 // Encodable is the interface implemented
 // by types that know how to write themselves
 // as MessagePack using a *msgp.Writer.
@@ -108,6 +116,7 @@ type Writer struct {
 	w    io.Writer
 	buf  []byte
 	wloc int
+	off  int64 // bytes written to w
 }
 
 // NewWriter returns a new *Writer.
@@ -149,6 +158,7 @@ func (mw *Writer) flush() error {
 		return nil
 	}
 	n, err := mw.w.Write(mw.buf[:mw.wloc])
+	mw.off += int64(n)
 	if err != nil {
 		if n > 0 {
 			mw.wloc = copy(mw.buf, mw.buf[n:mw.wloc])
@@ -270,7 +280,9 @@ func (mw *Writer) Write(p []byte) (int, error) {
 			return 0, err
 		}
 		if l > len(mw.buf) {
-			return mw.w.Write(p)
+			n, err := mw.w.Write(p)
+			mw.off += int64(n)
+			return n, err
 		}
 	}
 	mw.wloc += copy(mw.buf[mw.wloc:], p)
@@ -285,7 +297,8 @@ func (mw *Writer) writeString(s string) error {
 			return err
 		}
 		if l > len(mw.buf) {
-			_, err := io.WriteString(mw.w, s)
+			n, err := io.WriteString(mw.w, s)
+			mw.off += int64(n)
 			return err
 		}
 	}
@@ -298,6 +311,7 @@ func (mw *Writer) Reset(w io.Writer) {
 	mw.buf = mw.buf[:cap(mw.buf)]
 	mw.w = w
 	mw.wloc = 0
+	mw.off = 0
 }
 
 // WriteMapHeader writes a map header of the given
@@ -326,6 +340,83 @@ func (mw *Writer) WriteArrayHeader(sz uint32) error {
 	}
 }
 
+// ReservedHeader is an array or map header whose
+// size is set after its elements have been written.
+// See (*Writer).ReserveArrayHeader.
+type ReservedHeader struct {
+	off int64 // offset of the header in the Writer's output
+}
+
+// ReserveArrayHeader writes an array header whose size
+// isn't known yet, so that the elements can be written as
+// they are produced. Once they have all been written, call
+// FillHeader with the number of elements. The header always
+// takes 5 bytes, so it can hold any size.
+func (mw *Writer) ReserveArrayHeader() (ReservedHeader, error) {
+	return mw.reserve(marray32)
+}
+
+// ReserveMapHeader is like ReserveArrayHeader, but
+// writes a map header. Its size is the number of
+// key-value pairs.
+func (mw *Writer) ReserveMapHeader() (ReservedHeader, error) {
+	return mw.reserve(mmap32)
+}
+
+func (mw *Writer) reserve(prefix byte) (ReservedHeader, error) {
+	o, err := mw.require(5)
+	if err != nil {
+		return ReservedHeader{}, err
+	}
+	prefixu32(mw.buf[o:], prefix, 0)
+	return ReservedHeader{off: mw.off + int64(o)}, nil
+}
+
+// FillHeader sets the size of a header written by
+// ReserveArrayHeader or ReserveMapHeader. It must be
+// called before the Writer is Reset.
+//
+// If the header is still buffered, it is patched in place.
+// Otherwise, if the underlying writer implements io.WriterAt
+// and io.Seeker, as *os.File does, the header is patched at
+// its offset with WriteAt; this assumes that nothing else
+// has written to it since the header was reserved. If neither
+// is possible, FillHeader returns ErrHeaderFlushed. To avoid
+// that, either use a buffer large enough to hold the whole
+// array or map (see NewWriterSize), or write the elements
+// to a file first.
+func (mw *Writer) FillHeader(h ReservedHeader, sz uint32) error {
+	if h.off >= mw.off {
+		i := int(h.off - mw.off)
+		if i+5 > mw.wloc {
+			return ErrShortBytes
+		}
+		if lead := mw.buf[i]; lead != marray32 && lead != mmap32 {
+			return InvalidPrefixError(lead)
+		}
+		big.PutUint32(mw.buf[i+1:], sz)
+		return nil
+	}
+	wa, ok := mw.w.(io.WriterAt)
+	if !ok {
+		return ErrHeaderFlushed
+	}
+	sk, ok := mw.w.(io.Seeker)
+	if !ok {
+		return ErrHeaderFlushed
+	}
+	// the current position is the
+	// end of the flushed output
+	pos, err := sk.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	var b [4]byte
+	big.PutUint32(b[:], sz)
+	_, err = wa.WriteAt(b[:], pos-(mw.off-h.off)+1)
+	return err
+}
+
 // WriteNil writes a nil byte to the buffer
 func (mw *Writer) WriteNil() error {
 	return mw.push(mnil)
@@ -604,6 +695,12 @@ func (mw *Writer) WriteTime(t time.Time) error {
 	return nil
 }
 
+// WriteDuration writes a time.Duration to the writer
+// as an int64 number of nanoseconds.
+func (mw *Writer) WriteDuration(d time.Duration) error {
+	return mw.WriteInt64(int64(d))
+}
+
 // WriteIntf writes the concrete type of 'v'.
 // WriteIntf will error if 'v' is not one of the following:
 //  - A bool, float, string, []byte, int, uint, or complex
@@ -56,6 +56,40 @@ func AppendArrayHeader(b []byte, sz uint32) []byte {
 	}
 }
 
+// AppendReservedArrayHeader appends an array header
+// whose size isn't known yet, and returns the offset
+// of the header in the returned slice. Once all of the
+// elements have been appended, call FillReservedHeader
+// with the offset and the number of elements.
+func AppendReservedArrayHeader(b []byte) ([]byte, int) {
+	o, n := ensure(b, 5)
+	prefixu32(o[n:], marray32, 0)
+	return o, n
+}
+
+// AppendReservedMapHeader is like AppendReservedArrayHeader,
+// but appends a map header. Its size is the number of
+// key-value pairs.
+func AppendReservedMapHeader(b []byte) ([]byte, int) {
+	o, n := ensure(b, 5)
+	prefixu32(o[n:], mmap32, 0)
+	return o, n
+}
+
+// FillReservedHeader sets the size of the header at
+// offset 'at' in 'b', which must have been appended by
+// AppendReservedArrayHeader or AppendReservedMapHeader.
+func FillReservedHeader(b []byte, at int, sz uint32) error {
+	if at < 0 || at+5 > len(b) {
+		return ErrShortBytes
+	}
+	if lead := b[at]; lead != marray32 && lead != mmap32 {
+		return InvalidPrefixError(lead)
+	}
+	big.PutUint32(b[at+1:], sz)
+	return nil
+}
+
 // AppendNil appends a 'nil' byte to the slice
 func AppendNil(b []byte) []byte { return append(b, mnil) }
 
@@ -285,6 +319,12 @@ func AppendTime(b []byte, t time.Time) []byte {
 	return o
 }
 
+// AppendDuration appends a time.Duration to the slice
+// as an int64 number of nanoseconds
+func AppendDuration(b []byte, d time.Duration) []byte {
+	return AppendInt64(b, int64(d))
+}
+
 // AppendMapStrStr appends a map[string]string to the slice
 // as a MessagePack map with 'str'-type keys and values
 func AppendMapStrStr(b []byte, m map[string]string) []byte {
@@ -317,3 +317,42 @@ func BenchmarkAppendTime(b *testing.B) {
 		AppendTime(buf[0:0], t)
 	}
 }
+
+func TestAppendReservedHeader(t *testing.T) {
+	b := AppendNil(nil)
+	b, m := AppendReservedMapHeader(b)
+	b = AppendString(b, "rows")
+	b, a := AppendReservedArrayHeader(b)
+	for i := 0; i < 20; i++ {
+		b = AppendInt(b, i)
+	}
+	if err := FillReservedHeader(b, a, 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := FillReservedHeader(b, m, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := FillReservedHeader(b, 0, 1); err == nil {
+		t.Error("expected an error filling a nil byte")
+	}
+	if err := FillReservedHeader(b, len(b)-2, 1); err != ErrShortBytes {
+		t.Errorf("got error %v; want ErrShortBytes", err)
+	}
+
+	b, err := ReadNilBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sz, b, err := ReadMapHeaderBytes(b)
+	if err != nil || sz != 1 {
+		t.Fatalf("map header: %d, %v", sz, err)
+	}
+	b, err = Skip(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sz, b, err = ReadArrayHeaderBytes(b)
+	if err != nil || sz != 20 {
+		t.Fatalf("array header: %d, %v", sz, err)
+	}
+}
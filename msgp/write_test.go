@@ -2,8 +2,10 @@ package msgp
 
 import (
 	"bytes"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"os"
 	"testing"
 	"time"
 )
@@ -403,3 +405,85 @@ func BenchmarkWriteTime(b *testing.B) {
 		wr.WriteTime(t)
 	}
 }
+
+// writeRows writes 'n' ints in a reserved array
+// inside of a reserved map, and fills both headers
+func writeRows(w *Writer, n int) error {
+	m, err := w.ReserveMapHeader()
+	if err != nil {
+		return err
+	}
+	w.WriteString("rows")
+	a, err := w.ReserveArrayHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := w.WriteInt(i); err != nil {
+			return err
+		}
+	}
+	if err := w.FillHeader(a, uint32(n)); err != nil {
+		return err
+	}
+	return w.FillHeader(m, 1)
+}
+
+// checkRows checks the output of writeRows
+func checkRows(t *testing.T, data []byte, n int) {
+	v, _, err := ReadIntfBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, ok := v.(map[string]interface{})["rows"].([]interface{})
+	if !ok || len(rows) != n {
+		t.Fatalf("decoded %v; want %d rows", v, n)
+	}
+	for i := range rows {
+		if rows[i] != int64(i) {
+			t.Fatalf("row %d is %v", i, rows[i])
+		}
+	}
+}
+
+func TestReserveHeader(t *testing.T) {
+	// buffered
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteNil()
+	if err := writeRows(w, 100); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	checkRows(t, buf.Bytes()[1:], 100)
+
+	// flushed to a writer that can't be patched
+	buf.Reset()
+	w = NewWriterSize(&buf, 32)
+	if err := writeRows(w, 100); err != ErrHeaderFlushed {
+		t.Errorf("got error %v; want ErrHeaderFlushed", err)
+	}
+
+	// flushed to a file
+	f, err := ioutil.TempFile("", "msgp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	f.WriteString("header")
+	w = NewWriterSize(f, 32)
+	if err := writeRows(w, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRows(t, data[len("header"):], 1000)
+}
@@ -0,0 +1,201 @@
+// Package msgpgen generates MessagePack methods
+// for the types declared in a Go file or package
+// directory.
+//
+// It is the library form of the msgp command: instead
+// of writing files and printing progress, Generate returns
+// the generated source and the diagnostics produced along
+// the way. It keeps no global state, so it can be called
+// concurrently on many packages.
+package msgpgen
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tinylib/msgp/gen"
+	"github.com/tinylib/msgp/parse"
+	"github.com/tinylib/msgp/printer"
+)
+
+// Config configures a call to Generate.
+type Config struct {
+	// File is the input file or package directory.
+	File string
+
+	// Out is the name of the generated file. If it is
+	// empty, it is {File}_gen.go, or {File}/{package}_gen.go
+	// if File is a directory. If Out isn't a .go file, it is
+	// taken to be relative to File.
+	Out string
+
+	// Mode is the set of methods to generate.
+	Mode gen.Method
+
+	// Unexported also processes unexported types.
+	Unexported bool
+
+	// Strict makes fields that can't be serialized
+	// and unresolved identifiers errors.
+	Strict bool
+
+	// Tag is the struct tag that names fields
+	// without a msg tag, e.g. "json".
+	Tag string
+
+	// Report, if non-nil, is called with each
+	// diagnostic as it is produced.
+	Report func(Diagnostic)
+}
+
+// Diagnostic is a positioned message about the input.
+type Diagnostic = parse.Diagnostic
+
+// Result is the output of Generate.
+type Result struct {
+	Package  string // package name
+	File     string // name of the generated file
+	Source   []byte // generated methods, formatted
+	TestFile string // name of the generated test file
+	Tests    []byte // generated tests, formatted; nil unless Mode includes gen.Test
+}
+
+// Generate parses cfg.File and returns the generated
+// source, along with every diagnostic produced. If there
+// are no types requiring code generation, the Result is nil.
+// Nothing is written to disk.
+func Generate(cfg Config) (*Result, []Diagnostic, error) {
+	var diags []Diagnostic
+	report := func(d Diagnostic) {
+		diags = append(diags, d)
+		if cfg.Report != nil {
+			cfg.Report(d)
+		}
+	}
+	if cfg.Mode&^gen.Test == 0 {
+		return nil, diags, nil
+	}
+
+	fs, err := parse.File(cfg.File, parse.Options{
+		Unexported: cfg.Unexported,
+		Strict:     cfg.Strict,
+		Tag:        cfg.Tag,
+		Report:     report,
+	})
+	if err != nil {
+		return nil, diags, err
+	}
+	if len(fs.Identities) == 0 {
+		report(Diagnostic{Severity: parse.Info, Message: "no types requiring code generation were found"})
+		return nil, diags, nil
+	}
+
+	res := &Result{
+		Package: fs.Package,
+		File:    OutputFile(cfg.File, cfg.Out, fs.Package),
+	}
+	res.Source, res.Tests, err = printer.Generate(res.File, fs, cfg.Mode)
+	if err != nil {
+		return nil, diags, err
+	}
+	if res.Tests != nil {
+		res.TestFile = printer.TestFile(res.File)
+	}
+	return res, diags, nil
+}
+
+// OutputFile returns the name of the generated file
+// for the input 'file', the requested output name 'out'
+// (which may be empty) and the package name 'pkg'.
+func OutputFile(file, out, pkg string) string {
+	if out != "" {
+		if pre := strings.TrimPrefix(out, file); len(pre) > 0 &&
+			!strings.HasSuffix(out, ".go") {
+			return filepath.Join(file, out)
+		}
+		return out
+	}
+
+	if fi, err := os.Stat(file); err == nil && fi.IsDir() {
+		file = filepath.Join(file, pkg)
+	}
+	// new file name is old file name + _gen.go
+	return strings.TrimSuffix(file, ".go") + "_gen.go"
+}
+
+// Stale describes a generated file whose
+// contents on disk are out of date.
+type Stale struct {
+	File    string // file name
+	Missing bool   // the file doesn't exist
+	Line    int    // first line that differs
+	Added   int    // lines only in the generated source
+	Removed int    // lines only in the file on disk
+}
+
+func (s Stale) String() string {
+	if s.Missing {
+		return s.File + ": missing"
+	}
+	return fmt.Sprintf("%s: differs from line %d (+%d -%d lines)", s.File, s.Line, s.Added, s.Removed)
+}
+
+// Check compares the generated source with the
+// files on disk, and returns the files that differ.
+func (r *Result) Check() ([]Stale, error) {
+	var stale []Stale
+	files := []struct {
+		name string
+		src  []byte
+	}{{r.File, r.Source}, {r.TestFile, r.Tests}}
+	for _, f := range files {
+		if f.src == nil {
+			continue
+		}
+		disk, err := ioutil.ReadFile(f.name)
+		if os.IsNotExist(err) {
+			stale = append(stale, Stale{File: f.name, Missing: true})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(disk, f.src) {
+			stale = append(stale, compare(f.name, f.src, disk))
+		}
+	}
+	return stale, nil
+}
+
+// compare summarizes the differences between the
+// lines of 'want' and 'got'. Added and removed lines
+// are counted as a multiset, which is enough for a
+// summary and avoids computing a full diff.
+func compare(name string, want, got []byte) Stale {
+	wl := strings.Split(string(want), "\n")
+	gl := strings.Split(string(got), "\n")
+	s := Stale{File: name}
+	for s.Line < len(wl) && s.Line < len(gl) && wl[s.Line] == gl[s.Line] {
+		s.Line++
+	}
+	s.Line++
+	count := make(map[string]int, len(wl))
+	for _, l := range wl {
+		count[l]++
+	}
+	for _, l := range gl {
+		count[l]--
+	}
+	for _, n := range count {
+		if n > 0 {
+			s.Added += n
+		} else {
+			s.Removed -= n
+		}
+	}
+	return s
+}
@@ -0,0 +1,285 @@
+package msgpgen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinylib/msgp/gen"
+	"github.com/tinylib/msgp/parse"
+)
+
+const mode = gen.Encode | gen.Decode | gen.Marshal | gen.Unmarshal | gen.Size | gen.Test
+
+// writeSource writes 'src' to a.go in a new
+// temporary directory, and returns its name
+// and a function that removes the directory
+func writeSource(t *testing.T, src string) (string, func()) {
+	dir, err := ioutil.TempDir("", "msgpgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return name, func() { os.RemoveAll(dir) }
+}
+
+// write writes the generated files to disk
+func write(t *testing.T, res *Result) {
+	if err := ioutil.WriteFile(res.File, res.Source, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if res.Tests != nil {
+		if err := ioutil.WriteFile(res.TestFile, res.Tests, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	name, cleanup := writeSource(t, "package a\n\ntype S struct {\n\tA int\n\tC chan int\n}\n")
+	defer cleanup()
+
+	var reported []Diagnostic
+	res, diags, err := Generate(Config{
+		File:   name,
+		Mode:   mode,
+		Report: func(d Diagnostic) { reported = append(reported, d) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != len(diags) {
+		t.Errorf("reported %d diagnostics; returned %d", len(reported), len(diags))
+	}
+	var warning *Diagnostic
+	for i := range diags {
+		if diags[i].Severity == parse.Warning {
+			warning = &diags[i]
+		}
+	}
+	if warning == nil {
+		t.Fatalf("no warning for the unsupported field in %v", diags)
+	}
+	if !strings.Contains(warning.String(), "S: C:") {
+		t.Errorf("warning %q doesn't name the field", warning)
+	}
+
+	if res.Package != "a" {
+		t.Errorf("got package %q; want %q", res.Package, "a")
+	}
+	if want := strings.TrimSuffix(name, ".go") + "_gen.go"; res.File != want {
+		t.Errorf("got file %q; want %q", res.File, want)
+	}
+	if want := strings.TrimSuffix(name, ".go") + "_gen_test.go"; res.TestFile != want {
+		t.Errorf("got test file %q; want %q", res.TestFile, want)
+	}
+	if !strings.Contains(string(res.Source), ") MarshalMsg(") {
+		t.Error("MarshalMsg wasn't generated")
+	}
+	if res.Tests == nil {
+		t.Error("tests weren't generated")
+	}
+	if _, err := os.Stat(res.File); !os.IsNotExist(err) {
+		t.Errorf("Generate wrote %s", res.File)
+	}
+
+	// the same field is an error in strict mode
+	_, diags, err = Generate(Config{File: name, Mode: mode, Strict: true})
+	if err == nil {
+		t.Fatal("no error in strict mode")
+	}
+	if len(diags) == 0 || diags[len(diags)-1].Severity != parse.Error {
+		t.Errorf("the last diagnostic isn't an error: %v", diags)
+	}
+}
+
+func TestGenerateNothing(t *testing.T) {
+	name, cleanup := writeSource(t, "package a\n\nfunc f() {}\n")
+	defer cleanup()
+
+	res, _, err := Generate(Config{File: name, Mode: mode})
+	if err == nil || !strings.Contains(err.Error(), "no definitions") {
+		t.Errorf("got error %v for a file without types", err)
+	}
+	if res != nil {
+		t.Errorf("got a result for a file without types: %+v", res)
+	}
+
+	// no methods requested
+	res, _, err = Generate(Config{File: name, Mode: gen.Test})
+	if res != nil || err != nil {
+		t.Errorf("got %v, %v; want nil, nil", res, err)
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msgpgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		file, out, pkg string
+		want           string
+	}{
+		{"a.go", "", "a", "a_gen.go"},
+		{"path/to/a.go", "", "a", "path/to/a_gen.go"},
+		{"a.go", "b.go", "a", "b.go"},
+		{"a.go", "path/to/b.go", "a", "path/to/b.go"},
+		{dir, "", "pkg", filepath.Join(dir, "pkg_gen.go")},
+		{dir, "out", "pkg", filepath.Join(dir, "out")},
+		{dir, "out.go", "pkg", "out.go"},
+	}
+	for _, c := range cases {
+		if got := OutputFile(c.file, c.out, c.pkg); got != c.want {
+			t.Errorf("OutputFile(%q, %q, %q) = %q; want %q", c.file, c.out, c.pkg, got, c.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	name, cleanup := writeSource(t, "package a\n\ntype S struct {\n\tA int\n}\n")
+	defer cleanup()
+
+	res, _, err := Generate(Config{File: name, Mode: mode})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := res.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 2 || !stale[0].Missing || !stale[1].Missing {
+		t.Fatalf("got %v; want both files missing", stale)
+	}
+	if want := res.File + ": missing"; stale[0].String() != want {
+		t.Errorf("got %q; want %q", stale[0], want)
+	}
+
+	write(t, res)
+	stale, err = res.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 0 {
+		t.Fatalf("up-to-date files reported as stale: %v", stale)
+	}
+
+	// edit the third line of the generated file
+	lines := strings.Split(string(res.Source), "\n")
+	lines[2] = "// edited"
+	if err := ioutil.WriteFile(res.File, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stale, err = res.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 {
+		t.Fatalf("got %v; want one stale file", stale)
+	}
+	want := Stale{File: res.File, Line: 3, Added: 1, Removed: 1}
+	if stale[0] != want {
+		t.Errorf("got %+v; want %+v", stale[0], want)
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	const src = `package a
+
+type A struct {
+	M map[string][]int
+	N map[string]map[string]B
+	P *B
+	T []B
+}
+
+type B struct {
+	X [4]map[int]string
+	Y interface{}
+}
+`
+	name, cleanup := writeSource(t, src)
+	defer cleanup()
+
+	first, _, err := Generate(Config{File: name, Mode: mode})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// generate concurrently, to check that no
+	// state is shared between calls
+	results := make(chan *Result, 8)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			res, _, err := Generate(Config{File: name, Mode: mode})
+			if err != nil {
+				t.Error(err)
+			}
+			results <- res
+		}()
+	}
+	for i := 0; i < cap(results); i++ {
+		res := <-results
+		if res == nil {
+			continue
+		}
+		if !bytes.Equal(res.Source, first.Source) || !bytes.Equal(res.Tests, first.Tests) {
+			t.Fatal("the same input produced different output")
+		}
+	}
+
+	write(t, first)
+	if err := ioutil.WriteFile(name, []byte(strings.Replace(src, "\tP *B\n", "\tP *B\n\tQ string\n", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := Generate(Config{File: name, Mode: mode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := res.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0].File != res.File || stale[0].Added == 0 {
+		t.Errorf("got %v; want %s reported as stale", stale, res.File)
+	}
+}
+
+func TestDefaultOutOfRange(t *testing.T) {
+	cases := []struct {
+		field string
+		ok    bool
+	}{
+		{"U uint8 `msg:\"u,default=255\"`", true},
+		{"U uint8 `msg:\"u,default=300\"`", false},
+		{"I int16 `msg:\"i,default=-32769\"`", false},
+		{"F float32 `msg:\"f,default=3.5e38\"`", false},
+		{"F float64 `msg:\"f,default=1e40\"`", true},
+	}
+	for _, c := range cases {
+		name, cleanup := writeSource(t, "package a\n\ntype S struct {\n\t"+c.field+"\n}\n")
+		_, _, err := Generate(Config{File: name, Mode: mode})
+		cleanup()
+		if c.ok {
+			if err != nil {
+				t.Errorf("%s: %s", c.field, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: no error for a default out of range", c.field)
+		} else if want := "field " + c.field[:1] + ": invalid default value"; !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %q doesn't contain %q", c.field, err, want)
+		}
+	}
+}
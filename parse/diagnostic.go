@@ -0,0 +1,99 @@
+package parse
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity int
+
+const (
+	Info    Severity = iota // progress information
+	Warning                 // something will not be serialized as written
+	Error                   // generation failed
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return "<invalid>"
+	}
+}
+
+// A Diagnostic is a message produced while
+// parsing and processing a file.
+type Diagnostic struct {
+	Severity Severity
+	Pos      token.Position // position in the source, if known
+	Context  []string       // e.g. the file, type and field names
+	Message  string
+}
+
+// String returns the diagnostic as
+// "file:line:col: context: message", omitting
+// the position if it isn't known.
+func (d Diagnostic) String() string {
+	parts := make([]string, 0, len(d.Context)+2)
+	if d.Pos.IsValid() {
+		parts = append(parts, d.Pos.String())
+	}
+	parts = append(parts, d.Context...)
+	parts = append(parts, d.Message)
+	return strings.Join(parts, ": ")
+}
+
+// emit passes a diagnostic to the reporter, if any
+func (f *FileSet) emit(d Diagnostic) {
+	if f.report != nil {
+		f.report(d)
+	}
+}
+
+func (f *FileSet) logf(sev Severity, s string, v ...interface{}) {
+	f.emit(Diagnostic{
+		Severity: sev,
+		Context:  f.context(),
+		Message:  strings.TrimSuffix(fmt.Sprintf(s, v...), "\n"),
+	})
+}
+
+func (f *FileSet) infof(s string, v ...interface{}) { f.logf(Info, s, v...) }
+
+func (f *FileSet) infoln(s string) { f.logf(Info, "%s", s) }
+
+func (f *FileSet) warnf(s string, v ...interface{}) { f.logf(Warning, s, v...) }
+
+func (f *FileSet) warnln(s string) { f.logf(Warning, "%s", s) }
+
+// context returns a copy of the logging state
+func (f *FileSet) context() []string {
+	return append([]string(nil), f.logctx...)
+}
+
+// typeContext returns the logging state without
+// the file name, e.g. the type and field names
+// of a diagnostic that has a position
+func (f *FileSet) typeContext() []string {
+	if len(f.logctx) < 2 {
+		return nil
+	}
+	return append([]string(nil), f.logctx[1:]...)
+}
+
+// push logging state
+func (f *FileSet) pushstate(s string) {
+	f.logctx = append(f.logctx, s)
+}
+
+// pop logging state
+func (f *FileSet) popstate() {
+	f.logctx = f.logctx[:len(f.logctx)-1]
+}
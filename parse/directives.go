@@ -3,6 +3,7 @@ package parse
 import (
 	"fmt"
 	"go/ast"
+	"strconv"
 	"strings"
 
 	"github.com/tinylib/msgp/gen"
@@ -13,30 +14,41 @@ const linePrefix = "//msgp:"
 // func(args, fileset)
 type directive func([]string, *FileSet) error
 
-// func(passName, args, printer)
-type passDirective func(gen.Method, []string, *gen.Printer) error
+// func(passName, args, fileset, printer)
+type passDirective func(gen.Method, []string, *FileSet, *gen.Printer) error
 
 // map of all recognized directives
 //
 // to add a directive, define a func([]string, *FileSet) error
 // and then add it to this list.
 var directives = map[string]directive{
-	"shim":   applyShim,
-	"ignore": ignore,
-	"tuple":  astuple,
+	"shim":         applyShim,
+	"ignore":       ignore,
+	"tuple":        astuple,
+	"clearomitted": clearomitted,
+	"intkeys":      intkeys,
+	"binmarshal":   binmarshal,
+	"textmarshal":  textmarshal,
+	"union":        applyUnion,
+	"extension":    extension,
+	"sql":          sqlmethods,
+	"enum-string":  enumString,
+	"strict":       strictmode,
+	"tag":          filemode,
+	"naming":       filemode,
 }
 
 var passDirectives = map[string]passDirective{
 	"ignore": passignore,
 }
 
-func passignore(m gen.Method, text []string, p *gen.Printer) error {
-	pushstate(m.String())
+func passignore(m gen.Method, text []string, f *FileSet, p *gen.Printer) error {
+	f.pushstate(m.String())
 	for _, a := range text {
 		p.ApplyDirective(m, gen.IgnoreTypename(a))
-		infof("ignoring %s\n", a)
+		f.infof("ignoring %s\n", a)
 	}
-	popstate()
+	f.popstate()
 	return nil
 }
 
@@ -84,17 +96,57 @@ func applyShim(text []string, f *FileSet) error {
 			be.ShimMode = gen.Cast
 		case "convert":
 			be.ShimMode = gen.Convert
+		case "convertfrom":
+			be.ShimMode = gen.ConvertFrom
+		case "convertto":
+			be.ShimMode = gen.ConvertTo
 		default:
-			return fmt.Errorf("invalid shim mode; found %s, expected 'cast' or 'convert", modestr)
+			return fmt.Errorf("invalid shim mode; found %s, expected 'cast', 'convert', 'convertfrom' or 'convertto'", modestr)
 		}
+	} else {
+		be.ShimMode = f.shimMode(be.ShimToBase, be.ShimFromBase)
 	}
 
-	infof("%s -> %s\n", name, be.Value.String())
+	f.infof("%s -> %s\n", name, be.Value.String())
 	f.findShim(name, be)
 
 	return nil
 }
 
+// shimMode detects the shim mode from the
+// declarations of the shim functions, if they
+// are declared in the parsed files. Functions
+// that return (T, error) are fallible.
+func (f *FileSet) shimMode(to, from string) gen.ShimMode {
+	toErr := f.Fallible[to]
+	fromErr := f.Fallible[from]
+	switch {
+	case toErr && fromErr:
+		return gen.Convert
+	case toErr:
+		return gen.ConvertTo
+	case fromErr:
+		return gen.ConvertFrom
+	default:
+		return gen.Cast
+	}
+}
+
+//msgp:strict
+func strictmode(text []string, f *FileSet) error {
+	// strict mode is enabled for the file
+	// before parsing; see fileDirectives
+	return nil
+}
+
+//msgp:tag {tagname}
+//msgp:naming {snake_case|camelCase|lower}
+func filemode(text []string, f *FileSet) error {
+	// these apply to the whole file, and
+	// are read before parsing; see fileDirectives
+	return nil
+}
+
 //msgp:ignore {TypeA} {TypeB}...
 func ignore(text []string, f *FileSet) error {
 	if len(text) < 2 {
@@ -102,9 +154,10 @@ func ignore(text []string, f *FileSet) error {
 	}
 	for _, item := range text[1:] {
 		name := strings.TrimSpace(item)
+		f.ignored[name] = true
 		if _, ok := f.Identities[name]; ok {
 			delete(f.Identities, name)
-			infof("ignoring %s\n", name)
+			f.infof("ignoring %s\n", name)
 		}
 	}
 	return nil
@@ -120,11 +173,293 @@ func astuple(text []string, f *FileSet) error {
 		if el, ok := f.Identities[name]; ok {
 			if st, ok := el.(*gen.Struct); ok {
 				st.AsTuple = true
-				infoln(name)
+				f.infoln(name)
 			} else {
-				warnf("%s: only structs can be tuples\n", name)
+				f.directiveErrorf("%s: only structs can be tuples\n", name)
+			}
+		}
+	}
+	return nil
+}
+
+//msgp:clearomitted {TypeA} {TypeB}...
+//
+// A cleared field whose type is a struct declared
+// in the same file (or package) gets the `default=`
+// values of its own fields; structs declared elsewhere
+// are only zeroed.
+func clearomitted(text []string, f *FileSet) error {
+	if len(text) < 2 {
+		return nil
+	}
+	for _, item := range text[1:] {
+		name := strings.TrimSpace(item)
+		if el, ok := f.Identities[name]; ok {
+			if st, ok := el.(*gen.Struct); ok {
+				setClearOmitted(st)
+				f.infoln(name)
+			} else {
+				f.directiveErrorf("%s: only structs can clear omitted fields\n", name)
+			}
+		}
+	}
+	return nil
+}
+
+// setClearOmitted marks st and any anonymous
+// structs nested inside of it, which don't
+// have a name of their own to mark
+func setClearOmitted(st *gen.Struct) {
+	st.ClearOmitted = true
+	for i := range st.Fields {
+		e := st.Fields[i].FieldElem
+		for {
+			switch x := e.(type) {
+			case *gen.Ptr:
+				e = x.Value
+				continue
+			case *gen.Slice:
+				e = x.Els
+				continue
+			case *gen.Array:
+				e = x.Els
+				continue
+			case *gen.Map:
+				e = x.Value
+				continue
+			case *gen.Struct:
+				setClearOmitted(x)
 			}
+			break
+		}
+	}
+}
+
+//msgp:intkeys {TypeA} {TypeB}...
+//
+// Every field must have a unique `msg:"#N"` tag;
+// otherwise, the type is an error.
+func intkeys(text []string, f *FileSet) error {
+	if len(text) < 2 {
+		return nil
+	}
+	for _, item := range text[1:] {
+		name := strings.TrimSpace(item)
+		el, ok := f.Identities[name]
+		if !ok {
+			continue
+		}
+		st, ok := el.(*gen.Struct)
+		if !ok {
+			f.directiveErrorf("%s: only structs can have integer keys\n", name)
+			continue
+		}
+		// check every field before changing any, so
+		// that a bad type is never half converted
+		nums := make([]uint64, len(st.Fields))
+		seen := make(map[uint64]string, len(st.Fields))
+		ok = true
+		for i := range st.Fields {
+			fd := &st.Fields[i]
+			if !strings.HasPrefix(fd.FieldTag, "#") {
+				f.typeErrorf(name, "%s.%s: integer keys require a `msg:\"#N\"` tag", name, fd.FieldName)
+				ok = false
+				continue
+			}
+			num, err := strconv.ParseUint(fd.FieldTag[1:], 10, 64)
+			if err != nil {
+				f.typeErrorf(name, "%s.%s: bad field number %q", name, fd.FieldName, fd.FieldTag)
+				ok = false
+				continue
+			}
+			if prev, dup := seen[num]; dup {
+				f.typeErrorf(name, "%s.%s: field number %d is already used by %s", name, fd.FieldName, num, prev)
+				ok = false
+				continue
+			}
+			seen[num] = fd.FieldName
+			nums[i] = num
+		}
+		if !ok {
+			continue
+		}
+		for i := range st.Fields {
+			st.Fields[i].FieldNum = nums[i]
+		}
+		st.IntKeys = true
+		f.infoln(name)
+	}
+	return nil
+}
+
+//msgp:extension {Type} {ExtensionNumber} [noregister]
+func extension(text []string, f *FileSet) error {
+	if len(text) != 3 && len(text) != 4 {
+		return fmt.Errorf("extension directive should have 2 or 3 arguments; found %d", len(text)-1)
+	}
+	name := strings.TrimSpace(text[1])
+	if _, ok := f.Identities[name]; !ok {
+		return fmt.Errorf("extension: unknown type %s", name)
+	}
+	num, err := strconv.ParseInt(strings.TrimSpace(text[2]), 10, 8)
+	if err != nil {
+		return fmt.Errorf("extension %s: bad extension number %q", name, text[2])
+	}
+	typ := int8(num)
+	// 3, 4 and 5 are used by Complex64, Complex128 and time.Time
+	if typ < 0 || (typ >= 3 && typ <= 5) {
+		return fmt.Errorf("extension %s: extension number %d is reserved", name, typ)
+	}
+	for other, n := range f.Extensions {
+		if n == typ && other != name {
+			return fmt.Errorf("extension %s: extension number %d is already used by %s", name, typ, other)
+		}
+	}
+	if len(text) == 4 {
+		if opt := strings.TrimSpace(text[3]); opt != "noregister" {
+			return fmt.Errorf("extension %s: unknown option %q", name, opt)
+		}
+		f.NoRegister[name] = true
+	}
+	f.Extensions[name] = typ
+	f.infof("%s as extension %d\n", name, typ)
+	return nil
+}
+
+//msgp:sql {TypeA} {TypeB}...
+func sqlmethods(text []string, f *FileSet) error {
+	if len(text) < 2 {
+		return nil
+	}
+	for _, item := range text[1:] {
+		name := strings.TrimSpace(item)
+		if _, ok := f.Identities[name]; !ok {
+			return fmt.Errorf("sql: unknown type %s", name)
+		}
+		f.SQL[name] = true
+		f.infoln(name)
+	}
+	return nil
+}
+
+//msgp:enum-string {Type} unknown:{Policy}
+//
+// The type's String method must have a value
+// receiver, since it is called on the constants.
+func enumString(text []string, f *FileSet) error {
+	if len(text) < 2 || len(text) > 3 {
+		return fmt.Errorf("enum-string directive should have 1 or 2 arguments; found %d", len(text)-1)
+	}
+	name := strings.TrimSpace(text[1])
+	el, ok := f.Identities[name]
+	if !ok {
+		return fmt.Errorf("enum-string: unknown type %s", name)
+	}
+	if b, ok := el.(*gen.BaseElem); !ok || !isInteger(b.Value) {
+		return fmt.Errorf("enum-string %s: only integer types can be enums", name)
+	}
+	if len(f.Consts[name]) == 0 {
+		return fmt.Errorf("enum-string %s: no constants of type %s", name, name)
+	}
+	if f.ptrStr[name] {
+		// the type would silently be encoded
+		// as an integer, so this is always an error
+		f.typeErrorf(name, "enum-string %s: String must have a value receiver, not *%s", name, name)
+		return nil
+	}
+	unknown := gen.UnknownIsError
+	if len(text) == 3 {
+		switch policy := strings.TrimPrefix(strings.TrimSpace(text[2]), "unknown:"); policy {
+		case "error":
+			unknown = gen.UnknownIsError
+		case "zero":
+			unknown = gen.UnknownIsZero
+		default:
+			return fmt.Errorf("enum-string %s: invalid unknown policy %q, expected 'error' or 'zero'", name, policy)
+		}
+	}
+
+	// the enum is a shim to string, using
+	// String() and the generated parse func
+	be := gen.Ident("string")
+	be.Alias(name)
+	be.ShimToBase = name + ".String"
+	be.ShimFromBase = gen.EnumFromString(name)
+	be.ShimMode = gen.ConvertFrom
+	f.Enums[name] = unknown
+	f.infof("%s as enum\n", name)
+	f.findShim(name, be)
+	return nil
+}
+
+func isInteger(p gen.Primitive) bool {
+	switch p {
+	case gen.Uint, gen.Uint8, gen.Uint16, gen.Uint32, gen.Uint64, gen.Byte,
+		gen.Int, gen.Int8, gen.Int16, gen.Int32, gen.Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+//msgp:binmarshal {TypeA} {TypeB}...
+func binmarshal(text []string, f *FileSet) error {
+	return marshalAs(text, f, gen.BinaryMarshaler)
+}
+
+//msgp:textmarshal {TypeA} {TypeB}...
+func textmarshal(text []string, f *FileSet) error {
+	return marshalAs(text, f, gen.TextMarshaler)
+}
+
+// marshalAs replaces the named types with elements
+// that are encoded through their encoding.BinaryMarshaler
+// or encoding.TextMarshaler implementations. No methods
+// are generated for the named types themselves.
+func marshalAs(text []string, f *FileSet, kind gen.Primitive) error {
+	for _, item := range text[1:] {
+		name := strings.TrimSpace(item)
+		be := gen.Ident(name)
+		if be.Value != gen.IDENT {
+			return fmt.Errorf("%s: %s is a primitive type", text[0], name)
+		}
+		be.Value = kind
+		f.infof("%s -> %s\n", name, kind.String())
+		f.findShim(name, be)
+		delete(f.Identities, name)
+	}
+	return nil
+}
+
+//msgp:union {Interface} {TypeA}[={tagA}] {*TypeB}[={tagB}]...
+func applyUnion(text []string, f *FileSet) error {
+	if len(text) < 3 {
+		return fmt.Errorf("union directive should have an interface and at least one type; found %d arguments", len(text)-1)
+	}
+	name := strings.TrimSpace(text[1])
+	u := &gen.Union{}
+	u.Alias(name)
+	tags := make(map[string]string, len(text)-2)
+	for _, item := range text[2:] {
+		typ := strings.TrimSpace(item)
+		tag := strings.TrimPrefix(typ, "*")
+		if i := strings.IndexByte(typ, '='); i >= 0 {
+			typ, tag = typ[:i], typ[i+1:]
+		}
+		if prev, ok := tags[tag]; ok {
+			return fmt.Errorf("%s: %s and %s have the same tag %q", name, prev, typ, tag)
+		}
+		tags[tag] = typ
+
+		var el gen.Elem
+		if strings.HasPrefix(typ, "*") {
+			el = &gen.Ptr{Value: gen.Ident(typ[1:])}
+		} else {
+			el = gen.Ident(typ)
 		}
+		u.Variants = append(u.Variants, gen.UnionVariant{Tag: tag, Type: el})
 	}
+	f.infof("%s -> %d types\n", name, len(u.Variants))
+	f.findShim(name, u)
 	return nil
 }
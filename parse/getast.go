@@ -5,40 +5,101 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"os"
 	"reflect"
 	"sort"
 	"strings"
 
 	"github.com/tinylib/msgp/gen"
-	"github.com/ttacon/chalk"
 )
 
 // A FileSet is the in-memory representation of a
 // parsed file.
 type FileSet struct {
-	Package    string              // package name
-	Specs      map[string]ast.Expr // type specs in file
-	Identities map[string]gen.Elem // processed from specs
-	Directives []string            // raw preprocessor directives
-	Imports    []*ast.ImportSpec   // imports
+	Package    string                     // package name
+	Specs      map[string]ast.Expr        // type specs in file
+	Identities map[string]gen.Elem        // processed from specs
+	Directives []string                   // raw preprocessor directives
+	Imports    []*ast.ImportSpec          // imports
+	Extensions map[string]int8            // types with generated msgp.Extension methods
+	NoRegister map[string]bool            // extensions without a generated init()
+	SQL        map[string]bool            // types with generated driver.Valuer and sql.Scanner methods
+	Fallible   map[string]bool            // top-level funcs that return (T, error)
+	Consts     map[string][]string        // typed constants, by type name
+	Enums      map[string]gen.UnknownEnum // enums encoded as strings
+	Strict     bool                       // fail on dropped fields and unresolved identifiers
+	Tag        string                     // struct tag to read when there is no msg tag
+
+	fset    *token.FileSet          // positions of parsed nodes
+	ptrStr  map[string]bool         // types with a pointer-receiver String method
+	strict  map[string]bool         // files with a //msgp:strict directive
+	tags    map[string]string       // struct tag set by //msgp:tag, by file
+	naming  map[string]string       // naming policy set by //msgp:naming, by file
+	errs    map[string][]Diagnostic // errors, by type name
+	dirFile []string                // file of each of Directives
+	curFile string                  // file of the directive being applied
+	ignored map[string]bool         // types named in //msgp:ignore
+	uses    map[string]identUse     // first use of each non-local identifier
+	report  func(Diagnostic)        // receives diagnostics; may be nil
+	logctx  []string                // logging context
+}
+
+// Options configure the parser.
+type Options struct {
+	Unexported bool             // also process unexported types
+	Strict     bool             // fail on dropped fields and unresolved identifiers
+	Tag        string           // struct tag to read when a field has no msg tag
+	Report     func(Diagnostic) // receives diagnostics as they are produced; may be nil
+}
+
+// identUse is where a non-local
+// identifier was first used
+type identUse struct {
+	pos token.Pos
+	ctx []string
 }
 
 // File parses a file at the relative path
 // provided and produces a new *FileSet.
 // If you pass in a path to a directory, the entire
 // directory will be parsed.
-// If unexport is false, only exported identifiers are included in the FileSet.
+// If opts.Unexported is false, only exported identifiers are included in the FileSet.
+// If opts.Strict is true, or for files with a //msgp:strict directive, fields that
+// can't be serialized, unresolved identifiers and directives that can't be applied
+// are errors rather than warnings.
+// Directives that can't be applied to a type, such as //msgp:intkeys on a struct
+// with missing or duplicate field numbers, are always errors.
+// If opts.Tag is set, fields without a msg tag take their name from that tag instead;
+// the //msgp:tag directive does the same for a single file.
 // If the resulting FileSet would be empty, an error is returned.
-func File(name string, unexported bool) (*FileSet, error) {
-	pushstate(name)
-	defer popstate()
+// Diagnostics are passed to opts.Report; File itself never prints anything.
+func File(name string, opts Options) (*FileSet, error) {
+	fset := token.NewFileSet()
 	fs := &FileSet{
 		Specs:      make(map[string]ast.Expr),
 		Identities: make(map[string]gen.Elem),
+		Extensions: make(map[string]int8),
+		NoRegister: make(map[string]bool),
+		SQL:        make(map[string]bool),
+		Fallible:   make(map[string]bool),
+		Consts:     make(map[string][]string),
+		Enums:      make(map[string]gen.UnknownEnum),
+		Strict:     opts.Strict,
+		Tag:        opts.Tag,
+		fset:       fset,
+		strict:     make(map[string]bool),
+		ptrStr:     make(map[string]bool),
+		tags:       make(map[string]string),
+		naming:     make(map[string]string),
+		errs:       make(map[string][]Diagnostic),
+		ignored:    make(map[string]bool),
+		uses:       make(map[string]identUse),
+		report:     opts.Report,
 	}
+	fs.pushstate(name)
+	defer fs.popstate()
 
-	fset := token.NewFileSet()
 	finfo, err := os.Stat(name)
 	if err != nil {
 		return nil, err
@@ -58,13 +119,16 @@ func File(name string, unexported bool) (*FileSet, error) {
 		}
 		fs.Package = one.Name
 		for _, fl := range one.Files {
-			pushstate(fl.Name.Name)
-			fs.Directives = append(fs.Directives, yieldComments(fl.Comments)...)
-			if !unexported {
+			fs.pushstate(fl.Name.Name)
+			fs.Directives = append(fs.Directives, fs.fileDirectives(fl)...)
+			fs.getFallible(fl)
+			fs.getPtrStrings(fl)
+			fs.getConsts(fl)
+			if !opts.Unexported {
 				ast.FileExports(fl)
 			}
 			fs.getTypeSpecs(fl)
-			popstate()
+			fs.popstate()
 		}
 	} else {
 		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
@@ -72,8 +136,11 @@ func File(name string, unexported bool) (*FileSet, error) {
 			return nil, err
 		}
 		fs.Package = f.Name.Name
-		fs.Directives = yieldComments(f.Comments)
-		if !unexported {
+		fs.Directives = fs.fileDirectives(f)
+		fs.getFallible(f)
+		fs.getPtrStrings(f)
+		fs.getConsts(f)
+		if !opts.Unexported {
 			ast.FileExports(f)
 		}
 		fs.getTypeSpecs(f)
@@ -86,31 +153,179 @@ func File(name string, unexported bool) (*FileSet, error) {
 	fs.process()
 	fs.applyDirectives()
 	fs.propInline()
+	fs.linkStructs()
 
+	if err := fs.typeErrs(); err != nil {
+		return nil, err
+	}
 	return fs, nil
 }
 
+// fileDirectives returns the directives in 'f', and
+// notes the //msgp:strict, //msgp:tag and //msgp:naming
+// directives, which have to be known before parsing
+func (fs *FileSet) fileDirectives(f *ast.File) []string {
+	dirs := yieldComments(f.Comments)
+	name := fs.fset.Position(f.Pos()).Filename
+	fs.curFile = name
+	for _, d := range dirs {
+		fs.dirFile = append(fs.dirFile, name)
+		if strings.TrimSpace(d) == "strict" {
+			fs.strict[name] = true
+		}
+	}
+	for _, d := range dirs {
+		chunks := strings.Fields(d)
+		if len(chunks) == 0 {
+			continue
+		}
+		switch chunks[0] {
+		case "tag":
+			if len(chunks) != 2 {
+				fs.directiveErrorf("tag: expected one tag name, found %d\n", len(chunks)-1)
+				continue
+			}
+			fs.tags[name] = chunks[1]
+		case "naming":
+			if len(chunks) != 2 || namings[chunks[1]] == nil {
+				fs.directiveErrorf("naming: expected one of snake_case, camelCase or lower\n")
+				continue
+			}
+			fs.naming[name] = chunks[1]
+		}
+	}
+	return dirs
+}
+
+// fieldTag returns the name and options of the
+// field tag 'tag' for the field at 'pos'. The msg
+// tag is used if it is present; otherwise, the tag
+// set by //msgp:tag or FileSet.Tag, if any.
+func (fs *FileSet) fieldTag(pos token.Pos, tag reflect.StructTag) []string {
+	body, ok := tag.Lookup("msg")
+	if !ok {
+		alt := fs.tags[fs.fset.Position(pos).Filename]
+		if alt == "" {
+			alt = fs.Tag
+		}
+		if alt != "" {
+			body = tag.Get(alt)
+		}
+	}
+	return strings.Split(body, ",")
+}
+
+// fieldName returns the encoded name of the
+// untagged field 'name' at 'pos', according
+// to the file's //msgp:naming policy
+func (fs *FileSet) fieldName(pos token.Pos, name string) string {
+	if fn := namings[fs.naming[fs.fset.Position(pos).Filename]]; fn != nil {
+		return fn(name)
+	}
+	return name
+}
+
+// isStrict returns whether strict mode
+// applies to the node at 'pos'
+func (fs *FileSet) isStrict(pos token.Pos) bool {
+	return fs.Strict || fs.strict[fs.fset.Position(pos).Filename]
+}
+
+// dropf reports that the node at 'pos' won't be
+// serialized. In strict mode, this is an error
+// for the type being processed; otherwise it is
+// only a warning.
+func (fs *FileSet) dropf(pos token.Pos, format string, args ...interface{}) {
+	if !fs.isStrict(pos) {
+		fs.warnf(format, args...)
+		return
+	}
+	typ := ""
+	if len(fs.logctx) > 1 {
+		typ = fs.logctx[1]
+	}
+	fs.errs[typ] = append(fs.errs[typ], Diagnostic{
+		Severity: Error,
+		Pos:      fs.fset.Position(pos),
+		Context:  fs.typeContext(),
+		Message:  strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"),
+	})
+}
+
+// directiveErrorf reports a directive that can't
+// be applied. In strict mode, this is an error;
+// otherwise it is only a warning.
+func (fs *FileSet) directiveErrorf(format string, args ...interface{}) {
+	if !fs.Strict && !fs.strict[fs.curFile] {
+		fs.warnf(format, args...)
+		return
+	}
+	fs.typeErrorf("", format, args...)
+}
+
+// typeErrorf records an error that
+// prevents 'typ' from being generated
+func (fs *FileSet) typeErrorf(typ string, format string, args ...interface{}) {
+	fs.errs[typ] = append(fs.errs[typ], Diagnostic{
+		Severity: Error,
+		Context:  fs.context(),
+		Message:  strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"),
+	})
+}
+
+// typeErrs returns the errors for
+// the types that haven't been ignored
+func (fs *FileSet) typeErrs() error {
+	names := make([]string, 0, len(fs.errs))
+	for typ := range fs.errs {
+		if !fs.ignored[typ] {
+			names = append(names, typ)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	var msgs []string
+	for _, typ := range names {
+		for _, d := range fs.errs[typ] {
+			fs.emit(d)
+			msgs = append(msgs, d.String())
+		}
+	}
+	return fmt.Errorf("%d error(s):\n%s", len(msgs), strings.Join(msgs, "\n"))
+}
+
 // applyDirectives applies all of the directives that
 // are known to the parser. additional method-specific
 // directives remain in f.Directives
 func (f *FileSet) applyDirectives() {
 	newdirs := make([]string, 0, len(f.Directives))
-	for _, d := range f.Directives {
+	newfiles := make([]string, 0, len(f.Directives))
+	for i, d := range f.Directives {
+		f.curFile = f.dirFile[i]
 		chunks := strings.Split(d, " ")
-		if len(chunks) > 0 {
-			if fn, ok := directives[chunks[0]]; ok {
-				pushstate(chunks[0])
-				err := fn(chunks, f)
-				if err != nil {
-					warnln(err.Error())
-				}
-				popstate()
-			} else {
-				newdirs = append(newdirs, d)
+		if fn, ok := directives[chunks[0]]; ok {
+			f.pushstate(chunks[0])
+			err := fn(chunks, f)
+			if err != nil {
+				f.directiveErrorf("%s", err)
 			}
+			f.popstate()
+			continue
+		}
+		// the rest are pass directives, which are
+		// applied by PrintTo, so check them here
+		if len(chunks) < 2 || strToMethod(strings.TrimSpace(chunks[0])) == 0 ||
+			passDirectives[strings.TrimSpace(chunks[1])] == nil {
+			f.directiveErrorf("unrecognized directive %q\n", d)
+			continue
 		}
+		newdirs = append(newdirs, d)
+		newfiles = append(newfiles, f.dirFile[i])
 	}
 	f.Directives = newdirs
+	f.dirFile = newfiles
 }
 
 // A linkset is a graph of unresolved
@@ -158,7 +373,9 @@ func (f *FileSet) resolve(ls linkset) {
 
 	// what's left can't be resolved
 	for name, elem := range ls {
-		warnf("couldn't resolve type %s (%s)\n", name, elem.TypeName())
+		f.pushstate(name)
+		f.dropf(f.Specs[name].Pos(), "couldn't resolve type %s (%s)\n", name, elem.TypeName())
+		f.popstate()
 	}
 }
 
@@ -169,11 +386,16 @@ func (f *FileSet) process() {
 	deferred := make(linkset)
 parse:
 	for name, def := range f.Specs {
-		pushstate(name)
+		// interfaces can only be encoded
+		// as unions; see //msgp:union
+		if _, ok := def.(*ast.InterfaceType); ok {
+			continue parse
+		}
+		f.pushstate(name)
 		el := f.parseExpr(def)
 		if el == nil {
-			warnln("failed to parse")
-			popstate()
+			f.dropf(def.Pos(), "unsupported type %s\n", types.ExprString(def))
+			f.popstate()
 			continue parse
 		}
 		// push unresolved identities into
@@ -181,12 +403,12 @@ parse:
 		// we've handled every possible named type.
 		if be, ok := el.(*gen.BaseElem); ok && be.Value == gen.IDENT {
 			deferred[name] = be
-			popstate()
+			f.popstate()
 			continue parse
 		}
 		el.Alias(name)
 		f.Identities[name] = el
-		popstate()
+		f.popstate()
 	}
 
 	if len(deferred) > 0 {
@@ -227,21 +449,21 @@ loop:
 			}
 			m := strToMethod(chunks[0])
 			if m == 0 {
-				warnf("unknown pass name: %q\n", chunks[0])
+				f.warnf("unknown pass name: %q\n", chunks[0])
 				continue loop
 			}
 			if fn, ok := passDirectives[chunks[1]]; ok {
-				pushstate(chunks[1])
-				err := fn(m, chunks[2:], p)
+				f.pushstate(chunks[1])
+				err := fn(m, chunks[2:], f, p)
 				if err != nil {
-					warnf("error applying directive: %s\n", err)
+					f.warnf("error applying directive: %s\n", err)
 				}
-				popstate()
+				f.popstate()
 			} else {
-				warnf("unrecognized directive %q\n", chunks[1])
+				f.warnf("unrecognized directive %q\n", chunks[1])
 			}
 		} else {
-			warnf("empty directive: %q\n", d)
+			f.warnf("empty directive: %q\n", d)
 		}
 	}
 }
@@ -255,14 +477,35 @@ func (f *FileSet) PrintTo(p *gen.Printer) error {
 	sort.Strings(names)
 	for _, name := range names {
 		el := f.Identities[name]
-		el.SetVarname("z")
-		pushstate(el.TypeName())
+		f.pushstate(el.TypeName())
 		err := p.Print(el)
-		popstate()
+		if err == nil {
+			if typ, ok := f.Extensions[name]; ok {
+				err = p.PrintExtension(el, typ, !f.NoRegister[name])
+			}
+		}
+		if err == nil && f.SQL[name] {
+			err = p.PrintSQL(el)
+		}
+		f.popstate()
 		if err != nil {
 			return err
 		}
 	}
+
+	// enums are printed even if the type
+	// itself is ignored, since the shims
+	// in other types still refer to them
+	enums := make([]string, 0, len(f.Enums))
+	for name := range f.Enums {
+		enums = append(enums, name)
+	}
+	sort.Strings(enums)
+	for _, name := range enums {
+		if err := p.PrintEnum(name, f.Consts[name], f.Enums[name]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -292,9 +535,18 @@ func (fs *FileSet) getTypeSpecs(f *ast.File) {
 						*ast.ArrayType,
 						*ast.StarExpr,
 						*ast.MapType,
-						*ast.Ident:
+						*ast.Ident,
+						*ast.InterfaceType:
 						fs.Specs[ts.Name.Name] = ts.Type
 
+					// named library types that we know
+					// how to convert, e.g. time.Duration
+					case *ast.SelectorExpr:
+						switch gen.Ident(stringify(ts.Type)).Value {
+						case gen.IDENT, gen.Ext, gen.BigInt, gen.BigFloat, gen.BigRat:
+						default:
+							fs.Specs[ts.Name.Name] = ts.Type
+						}
 					}
 				}
 			}
@@ -319,14 +571,14 @@ func (fs *FileSet) parseFieldList(fl *ast.FieldList) []gen.StructField {
 	}
 	out := make([]gen.StructField, 0, fl.NumFields())
 	for _, field := range fl.List {
-		pushstate(fieldName(field))
+		fs.pushstate(fieldName(field))
 		fds := fs.getField(field)
 		if len(fds) > 0 {
 			out = append(out, fds...)
 		} else {
-			warnln("ignored.")
+			fs.warnln("ignored.")
 		}
-		popstate()
+		fs.popstate()
 	}
 	return out
 }
@@ -334,13 +586,27 @@ func (fs *FileSet) parseFieldList(fl *ast.FieldList) []gen.StructField {
 // translate *ast.Field into []gen.StructField
 func (fs *FileSet) getField(f *ast.Field) []gen.StructField {
 	sf := make([]gen.StructField, 1)
-	var extension bool
+	var (
+		cast   gen.Primitive // forced base type, if any
+		castTo string        // tag option that forced it
+		codec  string        // per-field codec, if any
+	)
 	// parse tag; otherwise field name is field tag
 	if f.Tag != nil {
-		body := reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Get("msg")
-		tags := strings.Split(body, ",")
-		if len(tags) == 2 && tags[1] == "extension" {
-			extension = true
+		tags := fs.fieldTag(f.Pos(), reflect.StructTag(strings.Trim(f.Tag.Value, "`")))
+		for _, opt := range tags[1:] {
+			switch {
+			case opt == "extension":
+				cast, castTo = gen.Ext, opt
+			case opt == "binmarshal":
+				cast, castTo = gen.BinaryMarshaler, opt
+			case opt == "textmarshal":
+				cast, castTo = gen.TextMarshaler, opt
+			case strings.HasPrefix(opt, "default="):
+				sf[0].Default = strings.TrimPrefix(opt, "default=")
+			case strings.HasPrefix(opt, "codec="):
+				codec = strings.TrimPrefix(opt, "codec=")
+			}
 		}
 		// ignore "-" fields
 		if tags[0] == "-" {
@@ -350,8 +616,18 @@ func (fs *FileSet) getField(f *ast.Field) []gen.StructField {
 		sf[0].RawTag = f.Tag.Value
 	}
 
-	ex := fs.parseExpr(f.Type)
+	var ex gen.Elem
+	if codec != "" {
+		if cast != gen.Invalid {
+			fs.dropf(f.Pos(), "can't use codec=%s with %s.\n", codec, castTo)
+			return nil
+		}
+		ex = gen.CodecElem(types.ExprString(f.Type), codec)
+	} else {
+		ex = fs.parseExpr(f.Type)
+	}
 	if ex == nil {
+		fs.dropf(f.Pos(), "unsupported type %s\n", types.ExprString(f.Type))
 		return nil
 	}
 
@@ -364,35 +640,37 @@ func (fs *FileSet) getField(f *ast.Field) []gen.StructField {
 	default:
 		// this is for a multiple in-line declaration,
 		// e.g. type A struct { One, Two int }
+		def := sf[0].Default
 		sf = sf[0:0]
 		for _, nm := range f.Names {
 			sf = append(sf, gen.StructField{
-				FieldTag:  nm.Name,
+				FieldTag:  fs.fieldName(f.Pos(), nm.Name),
 				FieldName: nm.Name,
 				FieldElem: ex.Copy(),
+				Default:   def,
 			})
 		}
 		return sf
 	}
 	sf[0].FieldElem = ex
 	if sf[0].FieldTag == "" {
-		sf[0].FieldTag = sf[0].FieldName
+		sf[0].FieldTag = fs.fieldName(f.Pos(), sf[0].FieldName)
 	}
 
-	// validate extension
-	if extension {
+	// validate extension, binmarshal, textmarshal
+	if cast != gen.Invalid {
 		switch ex := ex.(type) {
 		case *gen.Ptr:
 			if b, ok := ex.Value.(*gen.BaseElem); ok {
-				b.Value = gen.Ext
+				b.Value = cast
 			} else {
-				warnln("couldn't cast to extension.")
+				fs.dropf(f.Pos(), "couldn't cast to %s.\n", castTo)
 				return nil
 			}
 		case *gen.BaseElem:
-			ex.Value = gen.Ext
+			ex.Value = cast
 		default:
-			warnln("couldn't cast to extension.")
+			fs.dropf(f.Pos(), "couldn't cast to %s.\n", castTo)
 			return nil
 		}
 	}
@@ -472,7 +750,8 @@ func (fs *FileSet) parseExpr(e ast.Expr) gen.Elem {
 		// everything else.
 		if b.Value == gen.IDENT {
 			if _, ok := fs.Specs[e.Name]; !ok {
-				warnf("non-local identifier: %s\n", e.Name)
+				fs.warnf("non-local identifier: %s\n", e.Name)
+				fs.use(e.Name, e.Pos())
 			}
 		}
 		return b
@@ -530,7 +809,11 @@ func (fs *FileSet) parseExpr(e ast.Expr) gen.Elem {
 		return &gen.Struct{Fields: fs.parseFieldList(e.Fields)}
 
 	case *ast.SelectorExpr:
-		return gen.Ident(stringify(e))
+		b := gen.Ident(stringify(e))
+		if b.Value == gen.IDENT {
+			fs.use(b.TypeName(), e.Pos())
+		}
+		return b
 
 	case *ast.InterfaceType:
 		// support `interface{}`
@@ -544,44 +827,82 @@ func (fs *FileSet) parseExpr(e ast.Expr) gen.Elem {
 	}
 }
 
-func infof(s string, v ...interface{}) {
-	pushstate(s)
-	fmt.Printf(chalk.Green.Color(strings.Join(logctx, ": ")), v...)
-	popstate()
-}
-
-func infoln(s string) {
-	pushstate(s)
-	fmt.Println(chalk.Green.Color(strings.Join(logctx, ": ")))
-	popstate()
-}
-
-func warnf(s string, v ...interface{}) {
-	pushstate(s)
-	fmt.Printf(chalk.Yellow.Color(strings.Join(logctx, ": ")), v...)
-	popstate()
+// use records the first use of a non-local identifier
+func (fs *FileSet) use(name string, pos token.Pos) {
+	if _, ok := fs.uses[name]; !ok {
+		fs.uses[name] = identUse{pos: pos, ctx: fs.typeContext()}
+	}
 }
 
-func warnln(s string) {
-	pushstate(s)
-	fmt.Println(chalk.Yellow.Color(strings.Join(logctx, ": ")))
-	popstate()
+// getFallible records the top-level funcs in the file
+// that return an error, which may be used as shims.
+// (It must run before unexported declarations are removed.)
+func (fs *FileSet) getFallible(f *ast.File) {
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil && returnsError(fd.Type) {
+			fs.Fallible[fd.Name.Name] = true
+		}
+	}
 }
 
-func fatalf(s string, v ...interface{}) {
-	pushstate(s)
-	fmt.Printf(chalk.Red.Color(strings.Join(logctx, ": ")), v...)
-	popstate()
+// getPtrStrings records the types in the file
+// whose String method has a pointer receiver,
+// which can't be called on their constants.
+func (fs *FileSet) getPtrStrings(f *ast.File) {
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Recv == nil || len(fd.Recv.List) != 1 || fd.Name.Name != "String" {
+			continue
+		}
+		if star, ok := fd.Recv.List[0].Type.(*ast.StarExpr); ok {
+			fs.ptrStr[stringify(star.X)] = true
+		}
+	}
 }
 
-var logctx []string
-
-// push logging state
-func pushstate(s string) {
-	logctx = append(logctx, s)
+// getConsts records the names of the typed
+// constants in the file, by type name.
+func (fs *FileSet) getConsts(f *ast.File) {
+	for _, d := range f.Decls {
+		g, ok := d.(*ast.GenDecl)
+		if !ok || g.Tok != token.CONST {
+			continue
+		}
+		// a spec without a type or values
+		// repeats the previous one, e.g.
+		//
+		//	const (
+		//		A Color = iota
+		//		B
+		//	)
+		var typ string
+		for _, s := range g.Specs {
+			vs := s.(*ast.ValueSpec)
+			switch {
+			case vs.Type != nil:
+				typ = stringify(vs.Type)
+			case len(vs.Values) > 0:
+				typ = ""
+			}
+			if typ == "" {
+				continue
+			}
+			for _, nm := range vs.Names {
+				if nm.Name != "_" {
+					fs.Consts[typ] = append(fs.Consts[typ], nm.Name)
+				}
+			}
+		}
+	}
 }
 
-// pop logging state
-func popstate() {
-	logctx = logctx[:len(logctx)-1]
+// returnsError returns whether a func
+// has the signature func(...) (T, error)
+func returnsError(ft *ast.FuncType) bool {
+	if ft.Results.NumFields() != 2 {
+		return false
+	}
+	res := ft.Results.List
+	id, ok := res[len(res)-1].Type.(*ast.Ident)
+	return ok && id.Name == "error"
 }
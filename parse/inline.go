@@ -1,6 +1,8 @@
 package parse
 
 import (
+	"sort"
+
 	"github.com/tinylib/msgp/gen"
 )
 
@@ -30,9 +32,9 @@ const maxComplex = 5
 
 // begin recursive search for identities with the
 // given name and replace them with be
-func (f *FileSet) findShim(id string, be *gen.BaseElem) {
+func (f *FileSet) findShim(id string, be gen.Elem) {
 	for name, el := range f.Identities {
-		pushstate(name)
+		f.pushstate(name)
 		switch el := el.(type) {
 		case *gen.Struct:
 			for i := range el.Fields {
@@ -47,13 +49,17 @@ func (f *FileSet) findShim(id string, be *gen.BaseElem) {
 		case *gen.Ptr:
 			f.nextShim(&el.Value, id, be)
 		}
-		popstate()
+		f.popstate()
 	}
 	// we'll need this at the top level as well
 	f.Identities[id] = be
 }
 
-func (f *FileSet) nextShim(ref *gen.Elem, id string, be *gen.BaseElem) {
+func (f *FileSet) nextShim(ref *gen.Elem, id string, be gen.Elem) {
+	if b, ok := (*ref).(*gen.BaseElem); ok && b.Value == gen.Codec {
+		// an explicit codec= wins over a shim
+		return
+	}
 	if (*ref).TypeName() == id {
 		vn := (*ref).Varname()
 		*ref = be.Copy()
@@ -78,8 +84,17 @@ func (f *FileSet) nextShim(ref *gen.Elem, id string, be *gen.BaseElem) {
 
 // propInline identifies and inlines candidates
 func (f *FileSet) propInline() {
-	for name, el := range f.Identities {
-		pushstate(name)
+	// inlining one type can change whether
+	// another is inlined, so visit them in a
+	// fixed order to get reproducible output
+	names := make([]string, 0, len(f.Identities))
+	for name := range f.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		el := f.Identities[name]
+		f.pushstate(name)
 		switch el := el.(type) {
 		case *gen.Struct:
 			for i := range el.Fields {
@@ -94,7 +109,35 @@ func (f *FileSet) propInline() {
 		case *gen.Ptr:
 			f.nextInline(&el.Value, name)
 		}
-		popstate()
+		f.popstate()
+	}
+}
+
+// linkStructs points the struct fields whose
+// type is a struct declared in the FileSet at its
+// declaration, so that the defaults of its fields
+// can be applied when the field is cleared.
+func (f *FileSet) linkStructs() {
+	for _, el := range f.Identities {
+		if st, ok := el.(*gen.Struct); ok {
+			f.linkFields(st)
+		}
+	}
+}
+
+func (f *FileSet) linkFields(st *gen.Struct) {
+	for i := range st.Fields {
+		switch el := st.Fields[i].FieldElem.(type) {
+		case *gen.BaseElem:
+			if el.Value != gen.IDENT {
+				continue
+			}
+			if decl, ok := f.Identities[el.TypeName()].(*gen.Struct); ok {
+				el.Struct = decl
+			}
+		case *gen.Struct:
+			f.linkFields(el)
+		}
 	}
 }
 
@@ -111,7 +154,7 @@ func (f *FileSet) nextInline(ref *gen.Elem, root string) {
 		typ := el.TypeName()
 		if el.Value == gen.IDENT && typ != root {
 			if node, ok := f.Identities[typ]; ok && node.Complexity() < maxComplex {
-				infof("inlining %s\n", typ)
+				f.infof("inlining %s\n", typ)
 
 				// This should never happen; it will cause
 				// infinite recursion.
@@ -125,7 +168,7 @@ func (f *FileSet) nextInline(ref *gen.Elem, root string) {
 				// this is the point at which we're sure that
 				// we've got a type that isn't a primitive,
 				// a library builtin, or a processed type
-				warnf("unresolved identifier: %s\n", typ)
+				f.unresolved(root, typ)
 			}
 		}
 	case *gen.Struct:
@@ -140,7 +183,27 @@ func (f *FileSet) nextInline(ref *gen.Elem, root string) {
 		f.nextInline(&el.Value, root)
 	case *gen.Ptr:
 		f.nextInline(&el.Value, root)
+	case *gen.Union:
+		// the concrete types are always
+		// encoded through their own methods
 	default:
 		panic("bad elem type")
 	}
 }
+
+// unresolved reports an unresolved identifier
+// used by the type 'root'. In strict mode, this
+// is an error at the identifier's first use.
+func (f *FileSet) unresolved(root, typ string) {
+	u, ok := f.uses[typ]
+	if !ok || !f.isStrict(u.pos) {
+		f.warnf("unresolved identifier: %s\n", typ)
+		return
+	}
+	f.errs[root] = append(f.errs[root], Diagnostic{
+		Severity: Error,
+		Pos:      f.fset.Position(u.pos),
+		Context:  u.ctx,
+		Message:  "unresolved identifier " + typ,
+	})
+}
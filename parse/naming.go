@@ -0,0 +1,64 @@
+package parse
+
+import (
+	"strings"
+	"unicode"
+)
+
+// namings are the field naming policies
+// accepted by //msgp:naming
+var namings = map[string]func(string) string{
+	"snake_case": snakeCase,
+	"camelCase":  camelCase,
+	"lower":      strings.ToLower,
+}
+
+// words splits a Go identifier into words,
+// keeping initialisms together, e.g.
+// "HTTPServerID" -> ["HTTP", "Server", "ID"]
+func words(name string) []string {
+	var out []string
+	rs := []rune(name)
+	start := 0
+	for i := 1; i < len(rs); i++ {
+		switch {
+		case rs[i] == '_':
+			if start < i {
+				out = append(out, string(rs[start:i]))
+			}
+			start = i + 1
+		case unicode.IsUpper(rs[i]) && !unicode.IsUpper(rs[i-1]) && rs[i-1] != '_':
+			// "userID" -> "user", "ID"
+			out = append(out, string(rs[start:i]))
+			start = i
+		case unicode.IsUpper(rs[i]) && i+1 < len(rs) && unicode.IsLower(rs[i+1]) && start < i:
+			// "HTTPServer" -> "HTTP", "Server"
+			out = append(out, string(rs[start:i]))
+			start = i
+		}
+	}
+	if start < len(rs) {
+		out = append(out, string(rs[start:]))
+	}
+	return out
+}
+
+// snakeCase converts "UserID" to "user_id"
+func snakeCase(name string) string {
+	w := words(name)
+	for i := range w {
+		w[i] = strings.ToLower(w[i])
+	}
+	return strings.Join(w, "_")
+}
+
+// camelCase converts "UserID" to "userID"
+// and "HTTPServer" to "httpServer"
+func camelCase(name string) string {
+	w := words(name)
+	if len(w) == 0 {
+		return name
+	}
+	w[0] = strings.ToLower(w[0])
+	return strings.Join(w, "")
+}
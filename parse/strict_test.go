@@ -0,0 +1,134 @@
+package parse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseSource parses 'src' as a file, and
+// returns the warnings and the error
+func parseSource(t *testing.T, src string, opts Options) ([]string, error) {
+	dir, err := ioutil.TempDir("", "msgp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	opts.Report = func(d Diagnostic) {
+		if d.Severity == Warning {
+			warnings = append(warnings, d.String())
+		}
+	}
+	_, err = File(name, opts)
+	return warnings, err
+}
+
+func TestStrict(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string // the error in strict mode
+	}{
+		{
+			name: "unresolved identifier",
+			src:  "type S struct {\n\tExtra other.Thing\n}\n",
+			want: "S: Extra: unresolved identifier other.Thing",
+		},
+		{
+			name: "unsupported field",
+			src:  "type S struct {\n\tC chan int\n}\n",
+			want: "S: C:",
+		},
+		{
+			name: "bad directive",
+			src:  "//msgp:extension S x\n\ntype S struct{ A int }\n",
+			want: `bad extension number "x"`,
+		},
+		{
+			name: "unknown type in directive",
+			src:  "//msgp:sql Missing\n\ntype S struct{ A int }\n",
+			want: "sql: unknown type Missing",
+		},
+		{
+			name: "directive on the wrong kind of type",
+			src:  "//msgp:tuple S\n\ntype S int\n",
+			want: "S: only structs can be tuples",
+		},
+		{
+			name: "unrecognized directive",
+			src:  "//msgp:bogus S\n\ntype S struct{ A int }\n",
+			want: `unrecognized directive "bogus S"`,
+		},
+		{
+			name: "bad naming",
+			src:  "//msgp:naming kebab\n\ntype S struct{ A int }\n",
+			want: "naming: expected one of",
+		},
+	}
+	for _, tc := range cases {
+		src := "package a\n\n" + tc.src
+		warnings, err := parseSource(t, src, Options{})
+		if err != nil {
+			t.Errorf("%s: unexpected error without strict mode: %s", tc.name, err)
+		}
+		if len(warnings) == 0 {
+			t.Errorf("%s: expected a warning without strict mode", tc.name)
+		}
+
+		for _, opts := range []struct {
+			src  string
+			opts Options
+		}{
+			{"package a\n\n//msgp:strict\n\n" + tc.src, Options{}},
+			{src, Options{Strict: true}},
+		} {
+			_, err := parseSource(t, opts.src, opts.opts)
+			if err == nil {
+				t.Errorf("%s: expected an error in strict mode", tc.name)
+			} else if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("%s: got error %q; want %q", tc.name, err, tc.want)
+			}
+		}
+	}
+}
+
+func TestStrictIgnored(t *testing.T) {
+	// ignored types can't fail
+	src := "package a\n\n//msgp:strict\n//msgp:ignore S\n\ntype S struct {\n\tC chan int\n}\n\ntype T struct{ A int }\n"
+	if _, err := parseSource(t, src, Options{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntKeysErrors(t *testing.T) {
+	// bad field numbers are errors even without strict mode
+	for _, fields := range []string{
+		"A int `msg:\"#1\"`\n\tB int `msg:\"#1\"`",
+		"A int `msg:\"#1\"`\n\tB int",
+		"A int `msg:\"#x\"`",
+	} {
+		src := "package a\n\n//msgp:intkeys S\n\ntype S struct {\n\t" + fields + "\n}\n"
+		if _, err := parseSource(t, src, Options{}); err == nil {
+			t.Errorf("expected an error for fields %s", fields)
+		}
+	}
+}
+
+func TestEnumPointerString(t *testing.T) {
+	src := "package a\n\n//msgp:enum-string C\n\ntype C int\n\nconst A C = 1\n\nfunc (c *C) String() string { return \"a\" }\n"
+	_, err := parseSource(t, src, Options{})
+	if err == nil || !strings.Contains(err.Error(), "String must have a value receiver") {
+		t.Errorf("got error %v for a pointer-receiver String", err)
+	}
+	src = strings.Replace(src, "(c *C)", "(c C)", 1)
+	if _, err := parseSource(t, src, Options{}); err != nil {
+		t.Error(err)
+	}
+}
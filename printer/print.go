@@ -5,25 +5,45 @@ import (
 	"fmt"
 	"github.com/tinylib/msgp/gen"
 	"github.com/tinylib/msgp/parse"
-	"github.com/ttacon/chalk"
 	"golang.org/x/tools/imports"
 	"io"
 	"io/ioutil"
 	"strings"
 )
 
-func infof(s string, v ...interface{}) {
-	fmt.Printf(chalk.Magenta.Color(s), v...)
+// TestFile returns the name of the
+// test file that goes with 'file'.
+func TestFile(file string) string {
+	return strings.TrimSuffix(file, ".go") + "_test.go"
 }
 
 // PrintFile prints the methods for the provided list
 // of elements to the given file name and canonical
 // package path.
 func PrintFile(file string, f *parse.FileSet, mode gen.Method) error {
-	out, tests, err := generate(f, mode)
+	out, tests, err := Generate(file, f, mode)
 	if err != nil {
 		return err
 	}
+	if tests != nil {
+		err = ioutil.WriteFile(TestFile(file), tests, 0600)
+		if err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(file, out, 0600)
+}
+
+// Generate returns the formatted source of the methods
+// for the provided file set, and of their tests if 'mode'
+// includes gen.Test. The tests are nil otherwise. 'file' is
+// the name the source will be written to, which is used
+// when formatting imports.
+func Generate(file string, f *parse.FileSet, mode gen.Method) (out []byte, tests []byte, err error) {
+	outbuf, testbuf, err := generate(f, mode)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// we'll run goimports on the main file
 	// in another goroutine, and run it here
@@ -31,35 +51,31 @@ func PrintFile(file string, f *parse.FileSet, mode gen.Method) error {
 	// takes about the same amount of time as
 	// doing them in serial when GOMAXPROCS=1,
 	// and faster otherwise.
-	res := goformat(file, out.Bytes())
-	if tests != nil {
-		testfile := strings.TrimSuffix(file, ".go") + "_test.go"
-		err = format(testfile, tests.Bytes())
+	res := goformat(file, outbuf.Bytes())
+	if testbuf != nil {
+		tests, err = imports.Process(TestFile(file), testbuf.Bytes(), nil)
 		if err != nil {
-			return err
+			<-res
+			return nil, nil, err
 		}
-		infof(">>> Wrote and formatted \"%s\"\n", testfile)
 	}
-	err = <-res
-	if err != nil {
-		return err
+	r := <-res
+	if r.err != nil {
+		return nil, nil, r.err
 	}
-	return nil
+	return r.out, tests, nil
 }
 
-func format(file string, data []byte) error {
-	out, err := imports.Process(file, data, nil)
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(file, out, 0600)
+type formatted struct {
+	out []byte
+	err error
 }
 
-func goformat(file string, data []byte) <-chan error {
-	out := make(chan error, 1)
-	go func(file string, data []byte, end chan error) {
-		end <- format(file, data)
-		infof(">>> Wrote and formatted \"%s\"\n", file)
+func goformat(file string, data []byte) <-chan formatted {
+	out := make(chan formatted, 1)
+	go func(file string, data []byte, end chan formatted) {
+		src, err := imports.Process(file, data, nil)
+		end <- formatted{src, err}
 	}(file, data, out)
 	return out
 }